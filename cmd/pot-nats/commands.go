@@ -0,0 +1,383 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/datasance/nats-server/internal/admin"
+	"github.com/datasance/nats-server/internal/bootstrap"
+	"github.com/datasance/nats-server/internal/claimspush"
+	"github.com/datasance/nats-server/internal/config"
+	"github.com/datasance/nats-server/internal/jspurge"
+	"github.com/datasance/nats-server/internal/jwtcopy"
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/validate"
+	"github.com/datasance/nats-server/internal/webhook"
+)
+
+// command is a pot-nats subcommand. run returns the process exit status.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "Run the wrapper: start nats-server and keep it in sync with its inputs (default)", runCmd},
+		{"sync-jwt", "Sync the JWT mount dir to the JWT dir once", syncJWTCmd},
+		{"reconcile", "Purge JetStream data of accounts no longer in the resolver (--dry-run: only list them)", reconcileCmd},
+		{"push-claims", "Push all account JWTs to the running server via $SYS.REQ.CLAIMS.UPDATE", pushClaimsCmd},
+		{"bootstrap", "Create the operator, system account, system user creds and resolver config of a fresh deployment", bootstrapCmd},
+		{"validate", "Check config, accounts, TLS material, account JWTs and creds offline", validateCmd},
+		{"status", "Print the status of a running wrapper from its admin API", statusCmd},
+		{"webhook-test", "Send a signed test event to the webhook URLs", webhookTestCmd},
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(w, "\nAll commands read the same environment variables as run. Use \"%s <command> -h\" for flags.\n", filepath.Base(os.Args[0]))
+}
+
+// dispatch runs the command named by args[0], or run if args is empty or starts with a flag.
+func dispatch(args []string) int {
+	name := "run"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+	switch name {
+	case "help":
+		usage(os.Stdout)
+		return 0
+	}
+	for _, c := range commands {
+		if c.name == name {
+			return c.run(args)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage(os.Stderr)
+	return 2
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(filepath.Base(os.Args[0])+" "+name, flag.ContinueOnError)
+}
+
+// setupCLILogging logs to stderr for one-shot commands, so stdout carries only the command output.
+func setupCLILogging() {
+	logging.Setup(os.Stderr, config.GetNatsLogFormat(), config.GetNatsLogLevel(), 1)
+}
+
+func runCmd(args []string) int {
+	fs := newFlagSet("run")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	runWrapper()
+	return 0
+}
+
+func syncJWTCmd(args []string) int {
+	fs := newFlagSet("sync-jwt")
+	mountDir := fs.String("mount-dir", config.GetNatsJWTMountDir(), "JWT mount dir (NATS_JWT_MOUNT_DIR)")
+	jwtDir := fs.String("jwt-dir", config.GetNatsJWTDir(), "writable JWT resolver dir (NATS_JWT_DIR)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	setupCLILogging()
+	stats, err := jwtcopy.SyncMountToJWT(*mountDir, *jwtDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sync-jwt: %v\n", err)
+		return 1
+	}
+	fmt.Printf("added=%d updated=%d unchanged=%d removed=%d\n", stats.Added, stats.Updated, stats.Unchanged, stats.Removed)
+	return 0
+}
+
+// reconcileCmd runs the JetStream account reconciliation. A purge is sent to a running wrapper through its admin
+// API, so it is serialized with the wrapper's own reconciles; without an admin API it is refused while a wrapper is
+// running. A dry run only reads the store and the resolver dir and always runs here.
+func reconcileCmd(args []string) int {
+	fs := newFlagSet("reconcile")
+	dryRun := fs.Bool("dry-run", false, "only list the accounts that would be purged")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	conf := fs.String("config", config.GetNatsConf(), "server config, used to find the JetStream store dir (NATS_CONF)")
+	jwtDir := fs.String("jwt-dir", config.GetNatsJWTDir(), "JWT resolver dir (NATS_JWT_DIR)")
+	socket := fs.String("socket", config.GetNatsAdminSocket(), "admin API unix socket of a running wrapper (NATS_ADMIN_SOCKET)")
+	addr := fs.String("addr", "", "admin API TCP address (host:port) instead of the socket; uses NATS_ADMIN_TOKEN")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	setupCLILogging()
+	var res jspurge.Reconcile
+	var err error
+	switch {
+	case *dryRun:
+		res, err = runJetStreamReconcile(context.Background(), *conf, *jwtDir, true)
+	case *socket != "" || *addr != "":
+		var sent bool
+		res, sent, err = reconcileViaAdmin(admin.NewClient(*socket, *addr, config.GetNatsAdminToken()))
+		if !sent {
+			res, err = runJetStreamReconcile(context.Background(), *conf, *jwtDir, false)
+		}
+	case wrapperRunning():
+		fmt.Fprintln(os.Stderr, "reconcile: a wrapper is running and its admin API is disabled; use --dry-run, --addr, or stop the wrapper")
+		return 1
+	default:
+		res, err = runJetStreamReconcile(context.Background(), *conf, *jwtDir, false)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconcile: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		printJSON(res)
+	} else {
+		fmt.Printf("store_dir=%s resolver_accounts=%d to_purge=%d dry_run=%t\n", res.StoreDir, res.ResolverAccounts, len(res.ToPurge), res.DryRun)
+		for _, account := range res.ToPurge {
+			fmt.Printf("%s: JetStream data in %s but no %s.jwt in %s\n", account, filepath.Join(res.StoreDir, account), account, *jwtDir)
+		}
+		for _, account := range res.Initiated {
+			fmt.Printf("%s: purge initiated\n", account)
+		}
+		for account, msg := range res.Failed {
+			fmt.Printf("%s: purge failed: %s\n", account, msg)
+		}
+	}
+	if len(res.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// reconcileViaAdmin runs the reconciliation in the wrapper behind c. sent is false when no wrapper answers there.
+func reconcileViaAdmin(c *admin.Client) (res jspurge.Reconcile, sent bool, err error) {
+	body, err := c.Do(context.Background(), http.MethodPost, "/v1/reconcile")
+	if err != nil {
+		// Only a failed dial means no wrapper; any later error may come from a reconcile that did run.
+		if opErr := new(net.OpError); errors.As(err, &opErr) && opErr.Op == "dial" {
+			return res, false, nil
+		}
+		return res, true, err
+	}
+	return res, true, json.Unmarshal(body, &res)
+}
+
+// wrapperRunning reports whether a wrapper answers on the wrapper HTTP port (NATS_WRAPPER_HTTP_PORT) of this host.
+func wrapperRunning() bool {
+	port := config.GetNatsWrapperHTTPPort()
+	if port <= 0 {
+		return false
+	}
+	resp, err := (&http.Client{Timeout: 2 * time.Second}).Get("http://127.0.0.1:" + strconv.Itoa(port) + "/livez")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+func pushClaimsCmd(args []string) int {
+	fs := newFlagSet("push-claims")
+	jwtDir := fs.String("jwt-dir", config.GetNatsJWTDir(), "JWT resolver dir (NATS_JWT_DIR)")
+	url := fs.String("url", config.GetNatsClientURL(), "server client URL (NATS_CLIENT_URL)")
+	creds := fs.String("creds", config.GetNatsSysUserCredPath(), "system account user creds (NATS_SYS_USER_CRED_PATH)")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each claims update request")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	setupCLILogging()
+	if *creds == "" {
+		fmt.Fprintln(os.Stderr, "push-claims: system account creds not set (NATS_SYS_USER_CRED_PATH or --creds)")
+		return 1
+	}
+	res, err := claimspush.PushAccountJWTs(context.Background(), *jwtDir, *url, *creds, *timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "push-claims: %v\n", err)
+		return 1
+	}
+	fmt.Printf("pushed=%d failed=%d\n", res.Pushed, res.Failed)
+	if res.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func bootstrapCmd(args []string) int {
+	fs := newFlagSet("bootstrap")
+	confDir := filepath.Dir(config.GetNatsConf())
+	keysDir := fs.String("keys-dir", config.GetNatsKeysDir(), "owner-only dir of the operator, system account and system user seeds (NATS_KEYS_DIR)")
+	operatorJWT := fs.String("operator-jwt", filepath.Join(confDir, "operator.jwt"), "operator JWT path (default next to NATS_CONF)")
+	resolverConf := fs.String("resolver-conf", filepath.Join(confDir, "resolver.conf"), "resolver config fragment path (default next to NATS_CONF)")
+	jwtDir := fs.String("jwt-dir", config.GetNatsJWTDir(), "JWT resolver dir (NATS_JWT_DIR)")
+	creds := fs.String("creds", config.GetNatsSysUserCredPath(), "system account user creds (NATS_SYS_USER_CRED_PATH)")
+	operatorName := fs.String("operator", "pot", "operator name")
+	accountName := fs.String("account", "SYS", "system account name")
+	userName := fs.String("user", "sys", "system user name")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *creds == "" {
+		fmt.Fprintln(os.Stderr, "bootstrap: system account creds path not set (NATS_SYS_USER_CRED_PATH or --creds)")
+		return 1
+	}
+	res, err := bootstrap.Run(bootstrap.Options{
+		KeysDir:           *keysDir,
+		OperatorJWT:       *operatorJWT,
+		ResolverConf:      *resolverConf,
+		JWTDir:            *jwtDir,
+		CredsPath:         *creds,
+		OperatorName:      *operatorName,
+		SystemAccountName: *accountName,
+		SystemUserName:    *userName,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bootstrap: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		printJSON(res)
+		return 0
+	}
+	fmt.Printf("operator=%s system_account=%s system_user=%s\n", res.Operator, res.SystemAccount, res.SystemUser)
+	for _, f := range res.Files {
+		fmt.Printf("%-9s  %s\n", f.Status, f.Path)
+	}
+	fmt.Printf("Include %s from the server config.\n", *resolverConf)
+	return 0
+}
+
+func validateCmd(args []string) int {
+	fs := newFlagSet("validate")
+	conf := fs.String("config", config.GetNatsConf(), "server config (NATS_CONF)")
+	accounts := fs.String("accounts", config.GetNatsAccounts(), "accounts config (NATS_ACCOUNTS)")
+	sslDir := fs.String("ssl-dir", config.GetNatsSSLDir(), "TLS material dir (NATS_SSL_DIR)")
+	jwtDir := fs.String("jwt-dir", config.GetNatsJWTMountDir(), "account JWT dir to check (NATS_JWT_MOUNT_DIR)")
+	creds := fs.String("creds", config.GetNatsSysUserCredPath(), "system account user creds (NATS_SYS_USER_CRED_PATH)")
+	credsDir := fs.String("creds-dir", config.GetNatsCredsDir(), "creds files dir (NATS_CREDS_DIR)")
+	bin := fs.String("nats-server", config.GetNatsServerBin(), "nats-server binary used for the config syntax check (NATS_SERVER_BIN)")
+	asJSON := fs.Bool("json", false, "print the findings as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	now := time.Now()
+	var report validate.Report
+	report.Add(validate.Config(*bin, *conf))
+	report.Add(validate.File("accounts", *accounts))
+	report.Add(validate.TLSDir(*sslDir, now)...)
+	report.Add(validate.TLSConfig(*conf, now)...)
+	report.Add(validate.JWTDir(*jwtDir, now)...)
+	credsFiles := validate.CredsFiles(*credsDir)
+	if *creds != "" && !slices.Contains(credsFiles, *creds) {
+		credsFiles = append(credsFiles, *creds)
+	}
+	for _, p := range credsFiles {
+		report.Add(validate.InspectCreds(p, *jwtDir, now)...)
+	}
+	if *asJSON {
+		printJSON(report)
+	} else {
+		for _, f := range report.Findings {
+			line := fmt.Sprintf("%-4s  %-8s  %s", f.Severity, f.Check, f.Path)
+			if f.Message != "" {
+				line += ": " + f.Message
+			}
+			fmt.Println(line)
+		}
+	}
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
+
+func statusCmd(args []string) int {
+	fs := newFlagSet("status")
+	socket := fs.String("socket", config.GetNatsAdminSocket(), "admin API unix socket (NATS_ADMIN_SOCKET)")
+	addr := fs.String("addr", "", "admin API TCP address (host:port) instead of the socket; uses NATS_ADMIN_TOKEN")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *socket == "" && *addr == "" {
+		fmt.Fprintln(os.Stderr, "status: admin API socket disabled; use --addr")
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	body, err := admin.NewClient(*socket, *addr, config.GetNatsAdminToken()).Do(ctx, http.MethodGet, "/v1/status")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "status: %v\n", err)
+		return 1
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		os.Stdout.Write(body)
+		return 0
+	}
+	printJSON(v)
+	return 0
+}
+
+func webhookTestCmd(args []string) int {
+	fs := newFlagSet("webhook-test")
+	url := fs.String("url", "", "send to this URL instead of NATS_WEBHOOK_URLS")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	urls := config.GetNatsWebhookURLs()
+	if *url != "" {
+		urls = []string{*url}
+	}
+	if len(urls) == 0 {
+		fmt.Fprintln(os.Stderr, "webhook-test: no webhook URLs (NATS_WEBHOOK_URLS or --url)")
+		return 1
+	}
+	sender, err := webhook.New(webhook.Options{URLs: urls, Secret: config.GetNatsWebhookSecret(), Timeout: config.GetNatsWebhookTimeout()})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "webhook-test: %v\n", err)
+		return 1
+	}
+	if err := sender.Test(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "webhook-test: %v\n", err)
+		return 1
+	}
+	fmt.Printf("delivered to %d URL(s)\n", len(urls))
+	return 0
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
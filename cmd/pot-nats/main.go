@@ -14,28 +14,70 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
-	"log"
+	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/datasance/nats-server/internal/admin"
 	"github.com/datasance/nats-server/internal/claimspush"
 	"github.com/datasance/nats-server/internal/config"
+	"github.com/datasance/nats-server/internal/crashloop"
+	"github.com/datasance/nats-server/internal/drift"
+	"github.com/datasance/nats-server/internal/events"
+	"github.com/datasance/nats-server/internal/expiry"
+	"github.com/datasance/nats-server/internal/exporter"
+	"github.com/datasance/nats-server/internal/health"
+	"github.com/datasance/nats-server/internal/hooks"
 	"github.com/datasance/nats-server/internal/jspurge"
 	"github.com/datasance/nats-server/internal/jwtcopy"
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
 	"github.com/datasance/nats-server/internal/nats"
+	"github.com/datasance/nats-server/internal/natsclient"
+	"github.com/datasance/nats-server/internal/reload"
+	"github.com/datasance/nats-server/internal/resync"
+	"github.com/datasance/nats-server/internal/status"
+	"github.com/datasance/nats-server/internal/termination"
+	"github.com/datasance/nats-server/internal/validate"
 	"github.com/datasance/nats-server/internal/watch"
+	"github.com/datasance/nats-server/internal/watchdog"
+	"github.com/datasance/nats-server/internal/webhook"
+	natsgo "github.com/nats-io/nats.go"
 )
 
 const (
-	configWaitAttempts   = 30
-	configWaitInterval   = time.Second
-	reconcileStartDelay  = 3 * time.Second
-	reconcileAfterReload = 3 * time.Second
+	// eventsFlushTimeout bounds how long a crashing wrapper waits for the crash events to be delivered; it allows
+	// one webhook request at the default timeout.
+	eventsFlushTimeout = 5 * time.Second
+	// tlsRecheckInterval is how often a reload deferred for invalid TLS material checks it again.
+	tlsRecheckInterval = 2 * time.Second
 )
 
+var logger = logging.Component("main")
+
 func main() {
+	os.Exit(dispatch(os.Args[1:]))
+}
+
+// runWrapper starts nats-server and keeps it in sync with its inputs until it exits; it does not return.
+func runWrapper() {
+	recentLogs := logging.Setup(os.Stderr, config.GetNatsLogFormat(), config.GetNatsLogLevel(), config.GetNatsLogRingSize())
+	terminationLogPath := config.GetNatsTerminationLogPath()
+	writeTermination := func(reason string, err error) {
+		if err := termination.Write(terminationLogPath, termination.Summary(reason, err, recentLogs.Entries())); err != nil {
+			logger.Warn("Failed to write termination message", "path", terminationLogPath, logging.Err(err))
+		}
+	}
+	logging.OnFatal(func(msg string) { writeTermination(msg, nil) })
+
 	natsConf := config.GetNatsConf()
 	natsAccounts := config.GetNatsAccounts()
 	natsSSLDir := config.GetNatsSSLDir()
@@ -43,25 +85,112 @@ func main() {
 	natsJWTMountDir := config.GetNatsJWTMountDir()
 	natsCredsDir := config.GetNatsCredsDir()
 
-	// Wait for server config file to exist (e.g. volume-mounted by K8s or Pot agent)
-	for i := 0; i < configWaitAttempts; i++ {
-		if watch.FileExists(natsConf) {
-			break
-		}
-		if i == configWaitAttempts-1 {
-			log.Fatalf("NATS config file not found at %s after %d attempts", natsConf, configWaitAttempts)
+	// Reload policy: what each kind of change does to nats-server (NATS_SERVER_MODE preset, NATS_RELOAD_POLICY overrides).
+	mode := config.GetNatsServerMode()
+	policy, err := reload.ParsePolicy(mode, config.GetNatsReloadPolicy())
+	if err != nil {
+		logging.Fatal(logger, "Invalid reload policy", logging.Err(err))
+	}
+	logger.Info("Reload policy", "mode", mode, "policy", policy.String())
+
+	// Rate limit and maintenance windows: actions closer than NATS_RELOAD_MIN_INTERVAL, and restarts outside
+	// NATS_MAINTENANCE_WINDOWS, are deferred unless a cause is in NATS_MAINTENANCE_URGENT or it is an admin request.
+	limiter := &reload.Limiter{MinInterval: config.GetNatsReloadMinInterval()}
+	if limiter.Windows, err = reload.ParseWindows(config.GetNatsMaintenanceWindows()); err != nil {
+		logging.Fatal(logger, "Invalid maintenance windows", logging.Err(err))
+	}
+	for _, name := range config.GetNatsMaintenanceUrgent() {
+		c, err := reload.ParseCause(name)
+		if err != nil {
+			logging.Fatal(logger, "Invalid urgent maintenance cause", logging.Err(err))
 		}
-		log.Printf("Waiting for NATS config at %s...", natsConf)
-		time.Sleep(configWaitInterval)
+		limiter.Urgent = append(limiter.Urgent, c)
+	}
+	if len(limiter.Windows) > 0 {
+		logger.Info("Maintenance windows for restarts", "windows", config.GetNatsMaintenanceWindows(), "urgent", config.GetNatsMaintenanceUrgent())
+	}
+
+	// Wait for the server config file and the other required inputs (e.g. volume-mounted by K8s or Pot agent).
+	required, err := watch.ParseRequirements(config.GetNatsRequiredInputs())
+	if err != nil {
+		logging.Fatal(logger, "Invalid required inputs", logging.Err(err))
+	}
+	required = append([]watch.Requirement{{Kind: watch.RequireFile, Path: natsConf}}, required...)
+	if err := watch.WaitFor(context.Background(), required, config.GetNatsInputWaitTimeout()); err != nil {
+		logging.Fatal(logger, "Required inputs not found", logging.Err(err))
 	}
 
 	// Store initial config file hash so we only reload/restart when content actually changes (e.g. avoid ConfigMap re-render with same content).
-	var (
-		configHashMu   sync.Mutex
-		lastConfigHash [32]byte
-	)
-	if h, err := fileSHA256(natsConf); err == nil {
-		lastConfigHash = h
+	configHash := reload.NewConfigHash(natsConf)
+
+	// Lifecycle events on NATS (reloads, restarts, syncs, purges) for the rest of the platform.
+	if config.GetNatsEventsEnabled() {
+		err := events.SubscribeNATS(events.Default, events.NATSOptions{
+			URL:           config.GetNatsClientURL(),
+			CredsPath:     config.GetNatsEventsCredsPath(),
+			SubjectPrefix: config.GetNatsEventsSubjectPrefix(),
+			Stream:        config.GetNatsEventsStream(),
+			StreamMaxAge:  config.GetNatsEventsStreamMaxAge(),
+			QueueSize:     events.DefaultQueueSize,
+		})
+		if err != nil {
+			logger.Error("Lifecycle events disabled", logging.Err(err))
+		}
+	}
+	// Webhooks for the events that need attention (failed reloads, leaf restarts, crash loops, purges, rejected JWTs).
+	if urls := config.GetNatsWebhookURLs(); len(urls) > 0 {
+		sender, err := webhook.New(webhook.Options{
+			URLs:       urls,
+			Secret:     config.GetNatsWebhookSecret(),
+			Kinds:      config.GetNatsWebhookEvents(),
+			Timeout:    config.GetNatsWebhookTimeout(),
+			MaxRetries: config.GetNatsWebhookMaxRetries(),
+			QueueSize:  config.GetNatsWebhookQueueSize(),
+		})
+		if err != nil {
+			logging.Fatal(logger, "Invalid webhook configuration", logging.Err(err))
+		}
+		sender.Subscribe(events.Default)
+	}
+	// User-defined hooks at the reload pipeline steps.
+	if cmds := config.GetNatsHookCommands(); len(cmds) > 0 {
+		commands := make(map[hooks.Point][]string, len(cmds))
+		var points []string
+		for point, argv := range cmds {
+			commands[hooks.Point(point)] = argv
+			points = append(points, point)
+		}
+		sort.Strings(points)
+		hookRunner = hooks.New(commands, config.GetNatsHookTimeout())
+		logger.Info("Pipeline hooks enabled", "hooks", points)
+	}
+
+	// Pipeline state for /readyz (last sync, last reload, restart in progress).
+	tracker := new(status.Tracker)
+	server := new(nats.Server)
+
+	// Wrapper HTTP server: Prometheus metrics of the reload/sync control plane and, via the exporter, of nats-server itself.
+	if port := config.GetNatsWrapperHTTPPort(); port > 0 {
+		if monitorPort := config.GetNatsMonitorPort(); monitorPort > 0 && config.GetNatsExporterEnabled() {
+			metrics.Default.Register(exporter.New(monitorPort))
+		}
+		// The inputs waited for at startup must stay in place; the system user creds are required as well.
+		inputs := append([]watch.Requirement(nil), required...)
+		if credsPath := config.GetNatsSysUserCredPath(); credsPath != "" {
+			inputs = append(inputs, watch.Requirement{Kind: watch.RequireFile, Path: credsPath})
+		}
+		checker := health.New(server, tracker, config.GetNatsMonitorPort(), config.GetNatsHealthJetStream(), inputs, config.GetNatsExpiryFailReadiness())
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.HandleFunc("/livez", checker.Livez)
+		mux.HandleFunc("/readyz", checker.Readyz)
+		go func() {
+			addr := ":" + strconv.Itoa(port)
+			logger.Info("Wrapper HTTP server listening (/metrics, /livez, /readyz)", "addr", addr)
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				logger.Error("Wrapper HTTP server failed", logging.Err(err))
+			}
+		}()
 	}
 
 	// Serialize JWT sync so startup and watcher never run SyncMountToJWT concurrently.
@@ -69,196 +198,789 @@ func main() {
 	// Sync JWT mount dir to JWT dir before starting nats-server (so writable dir is populated).
 	if info, err := os.Stat(natsJWTMountDir); err == nil && info.IsDir() {
 		jwtSyncMu.Lock()
-		copied, removed, err := jwtcopy.SyncMountToJWT(natsJWTMountDir, natsJWTDir)
+		stats, err := syncJWT(natsJWTMountDir, natsJWTDir, "startup")
 		jwtSyncMu.Unlock()
+		tracker.SyncDone(err)
 		if err != nil {
-			log.Printf("JWT sync at startup failed: %v", err)
+			logger.Error("JWT sync at startup failed", logging.KeyCause, "startup", logging.Err(err))
 		} else {
-			log.Printf("JWT sync at startup: copied=%d removed=%d (mount=%s -> jwt=%s)", copied, removed, natsJWTMountDir, natsJWTDir)
+			logger.Info("JWT sync at startup", logging.KeyCause, "startup", "added", stats.Added, "updated", stats.Updated, "unchanged", stats.Unchanged, "removed", stats.Removed, "mount", natsJWTMountDir, "jwt_dir", natsJWTDir)
 		}
 	}
+	// Check the creds files once the resolver dir is populated, so the issuer accounts can be found.
+	inspectCreds(natsCredsDir, natsJWTDir, "startup")
 
-	server := new(nats.Server)
 	exitCh := make(chan error, 1)
-	var (
-		restartMu        sync.Mutex
-		restartRequested bool
-	)
+	// Set before nats-server is stopped on purpose, so the supervisor loop below does not treat the exit as a crash.
+	// watchdogRestart marks a restart outside the pipeline, after which the supervisor loop runs the post-start tasks.
+	var restartRequested, stopRequested, watchdogRestart atomic.Bool
 
 	startServer := func() {
 		if err := server.Start(natsConf, exitCh); err != nil {
-			log.Fatalf("Failed to start NATS server: %v", err)
+			logging.Fatal(logger, "Failed to start NATS server", logging.Err(err))
 		}
+		metrics.ReloadSucceeded()
+		events.Emit(events.TypeStart, map[string]any{"config": natsConf, "mode": config.GetNatsServerMode()})
 	}
 	startServer()
 
-	// One-time JetStream account reconciliation after startup (e.g. purge accounts removed while process was down).
-	go func() {
-		time.Sleep(reconcileStartDelay)
-		runJetStreamReconcile(natsConf, natsJWTDir)
-	}()
+	// requestRestart stops nats-server so the supervisor loop below starts it again instead of treating the exit as a crash.
+	// With lameDuck, the server first moves its clients away and exits after its lame duck duration.
+	// A failed stop rolls the flag back only if this call set it: a restart already in progress (e.g. the watchdog's)
+	// still needs it.
+	requestRestart := func(lameDuck bool) error {
+		set := restartRequested.CompareAndSwap(false, true)
+		tracker.SetRestarting(true)
+		stop := server.Stop
+		if lameDuck {
+			stop = server.LameDuck
+		}
+		if err := stop(); err != nil {
+			if set {
+				restartRequested.Store(false)
+				tracker.SetRestarting(false)
+			}
+			return err
+		}
+		return nil
+	}
 
 	ctx := context.Background()
 	debounce := 500 * time.Millisecond
 
-	// Coalescer: multiple watchers report a cause; one debounced reload runs, with reconcile+claims push only when jwt was a cause.
-	var (
-		coalescerMu     sync.Mutex
-		coalescerCauses map[string]bool
-		coalescerTimer  *time.Timer
-	)
-	scheduleReload := func(cause string) {
-		coalescerMu.Lock()
-		defer coalescerMu.Unlock()
-		if coalescerCauses == nil {
-			coalescerCauses = make(map[string]bool)
+	// Post-start tasks (JetStream reconcile, claims push) run once the server reports healthy instead of after a fixed delay.
+	// startedAfter is the time a restart was requested (zero for a reload), so the old process is not mistaken for the new one.
+	runPostStartTasks := func(ctx context.Context, startedAfter time.Time, reason string) {
+		if err := server.WaitReady(ctx, startedAfter, config.GetNatsReadyTimeout()); err != nil {
+			if ctx.Err() == nil {
+				logger.Error("Skipping JetStream reconcile and claims push", logging.KeyCause, reason, logging.Err(err))
+			}
+			return
 		}
-		coalescerCauses[cause] = true
-		if coalescerTimer != nil {
-			coalescerTimer.Stop()
+		runJetStreamReconcile(ctx, natsConf, natsJWTDir, false)
+		if ctx.Err() != nil {
+			return
 		}
-			coalescerTimer = time.AfterFunc(debounce, func() {
-			coalescerMu.Lock()
-			causes := coalescerCauses
-			coalescerCauses = nil
-			coalescerTimer = nil
-			coalescerMu.Unlock()
+		credsPath := config.GetNatsSysUserCredPath()
+		clientURL := config.GetNatsClientURL()
+		claimspush.PushAccountJWTs(ctx, natsJWTDir, clientURL, credsPath, 10*time.Second)
+	}
+
+	// Content digests of the watched inputs, so a resync can find changes the watchers missed.
+	resyncer := resync.New([]resync.Input{
+		{Cause: reload.CauseConfig, Paths: []string{natsConf}},
+		{Cause: reload.CauseAccounts, Paths: []string{natsAccounts}},
+		{Cause: reload.CauseSSL, Paths: []string{natsSSLDir}},
+		{Cause: reload.CauseCreds, Paths: []string{natsCredsDir}},
+	})
+
+	// TLS check before reloads: tlsInvalidSince is when the material was first found invalid, zero while it is valid.
+	// Only pipeline runs use it, and they are serialized.
+	tlsCheck, tlsSettleTimeout := config.GetNatsTLSCheck(), config.GetNatsTLSSettleTimeout()
+	var tlsInvalidSince time.Time
+
+	// Expiry rescans requested after a pipeline run handled rotated certificates, creds or JWTs; requests while one is
+	// queued are merged.
+	expiryKick := make(chan struct{}, 1)
+
+	// Reload pipeline: watchers trigger causes; one debounced run per batch passes the stages below, with reconcile and
+	// claims push afterwards only when jwt was a cause. A forced run (admin request) skips the unchanged-content check.
+	var pipeline *reload.Pipeline
+	pipeline = reload.New(ctx, reload.Options{
+		Debounce: debounce,
+		MaxWait:  config.GetNatsReloadMaxWait(),
+		Stages: []reload.Stage{
+			{Name: "pre-validate", Run: func(ctx context.Context, r *reload.Run) error {
+				err := hookRunner.Run(ctx, hooks.Input{Hook: hooks.PreValidate, Causes: r.Names(), Files: r.Files})
+				if err != nil {
+					logger.Warn("Reload after change vetoed", logging.KeyCause, r.Names(), logging.Err(err))
+					recordReload(r, "none", metrics.OutcomeVetoed, err)
+				}
+				return err
+			}},
+			// The pipeline has now seen the content of these inputs; a resync only reports later changes.
+			{Name: "seen", Run: func(_ context.Context, r *reload.Run) error {
+				resyncer.Refresh(r.Active()...)
+				return nil
+			}},
 			// Only treat config as changed if file content actually changed (avoids unnecessary reload/restart on ConfigMap re-render).
-			if causes["config"] {
-				if h, err := fileSHA256(natsConf); err != nil {
-					// Read failed; assume changed so we still react
-				} else {
-					configHashMu.Lock()
-					if h == lastConfigHash {
-						causes["config"] = false
-					} else {
-						lastConfigHash = h
+			reload.ContentStage(configHash),
+			// The policy (NATS_SERVER_MODE preset and NATS_RELOAD_POLICY) drops ignored causes and picks the strongest action.
+			reload.DecideStage(policy),
+			// The certificates, keys and CAs referenced by the config must be consistent before nats-server loads them: a
+			// rotation that lands tls.crt before tls.key is deferred until the set settles, or fails after
+			// NATS_TLS_SETTLE_TIMEOUT instead of reloading into a broken TLS state.
+			// Only runs acting on nats-server take a run held here along: a sync-only run must not wait for TLS to settle.
+			{Name: "tls", Joins: func(r, _ *reload.Run) bool { return r.Action != reload.ActionNone }, Run: func(_ context.Context, r *reload.Run) error {
+				if !tlsCheck || r.Action == reload.ActionNone || !r.HasAny(reload.CauseConfig, reload.CauseSSL) {
+					return nil
+				}
+				now := time.Now()
+				var failed []string
+				for _, f := range validate.TLSConfig(natsConf, now) {
+					if f.Severity == validate.SeverityFail {
+						failed = append(failed, f.Path+": "+f.Message)
 					}
-					configHashMu.Unlock()
 				}
-			}
-			if causes["jwt"] {
+				if len(failed) == 0 {
+					tlsInvalidSince = time.Time{}
+					return nil
+				}
+				err := fmt.Errorf("invalid TLS material: %s", strings.Join(failed, "; "))
+				first := tlsInvalidSince.IsZero()
+				if first {
+					tlsInvalidSince = now
+				}
+				if now.Sub(tlsInvalidSince) < tlsSettleTimeout {
+					if first {
+						logger.Warn("Reload after change waiting for consistent TLS material", logging.KeyCause, r.Names(), "action", r.Action, "timeout", tlsSettleTimeout, logging.Err(err))
+					}
+					if !r.Deferred() {
+						recordReload(r, string(r.Action), metrics.OutcomeDeferred, err)
+					}
+					return &reload.DeferError{Action: r.Action, Until: now.Add(tlsRecheckInterval), Reason: reload.DeferInvalidTLS}
+				}
+				tlsInvalidSince = time.Time{}
+				logger.Error("Reload after change skipped: invalid TLS material", logging.KeyCause, r.Names(), "action", r.Action, logging.Err(err))
+				recordReload(r, string(r.Action), metrics.OutcomeFailure, err)
+				tracker.ReloadDone(r.Names(), string(r.Action), metrics.OutcomeFailure, err)
+				if r.Has(reload.CauseConfig) {
+					// Not loaded: the next event must not be treated as unchanged content.
+					configHash.Forget()
+				}
+				return err
+			}},
+			// The JWT sync and creds check run before the limit stage: account changes never wait for a restart window.
+			reload.SyncStage(func(context.Context) {
 				jwtSyncMu.Lock()
-				copied, removed, err := jwtcopy.SyncMountToJWT(natsJWTMountDir, natsJWTDir)
+				stats, err := syncJWT(natsJWTMountDir, natsJWTDir, "jwt")
 				jwtSyncMu.Unlock()
+				tracker.SyncDone(err)
 				if err != nil {
-					log.Printf("JWT sync after mount dir change failed: %v", err)
+					logger.Error("JWT sync after mount dir change failed", logging.KeyCause, "jwt", logging.Err(err))
 				} else {
-					log.Printf("JWT sync after change: copied=%d removed=%d", copied, removed)
+					logger.Info("JWT sync after change", logging.KeyCause, "jwt", "added", stats.Added, "updated", stats.Updated, "unchanged", stats.Unchanged, "removed", stats.Removed)
 				}
-			}
-			// Leaf supports reload only for SSL/TLS cert changes; server supports full reload.
-			// For leaf with non-SSL changes (config, accounts, jwt, creds), SIGINT and restart so new config is loaded.
-			if config.GetNatsServerMode() != "leaf" || causes["ssl"] {
-				if err := server.Reload(); err != nil {
-					log.Printf("Reload after change: %v", err)
+			}),
+			// Changed creds, or JWTs their issuers may have been removed from, are checked again; findings are only logged.
+			{Name: "creds", Run: func(_ context.Context, r *reload.Run) error {
+				if r.HasAny(reload.CauseCreds, reload.CauseJWT) {
+					inspectCreds(natsCredsDir, natsJWTDir, strings.Join(r.Names(), ","))
 				}
-			} else if config.GetNatsServerMode() == "leaf" && (causes["config"] || causes["accounts"] || causes["creds"]) {
-				restartMu.Lock()
-				restartRequested = true
-				restartMu.Unlock()
-				if err := server.Stop(); err != nil {
-					log.Printf("Stop for restart after change: %v", err)
+				return nil
+			}},
+			// A deferred run is held and re-enters here; runs arriving meanwhile that join it (see reload.JoinHeld) are
+			// merged into it and checked again. A held JWT change is applied by claims push without waiting for its run.
+			{Name: "limit", Joins: reload.JoinHeld, Run: func(_ context.Context, r *reload.Run) error {
+				err := limiter.Check(r)
+				var d *reload.DeferError
+				if errors.As(err, &d) && !r.Deferred() {
+					logger.Info("Reload after change deferred", logging.KeyCause, r.Names(), "action", r.Action, "until", d.Until, "reason", d.Reason)
+					recordReload(r, string(r.Action), metrics.OutcomeDeferred, nil)
+					if r.Has(reload.CauseJWT) {
+						pipeline.StartPost(func(ctx context.Context) { runPostStartTasks(ctx, time.Time{}, "JWT change") })
+					}
 				}
-			}
-			if causes["jwt"] {
-				go func() {
-					time.Sleep(reconcileAfterReload)
-					runJetStreamReconcile(natsConf, natsJWTDir)
-					credsPath := config.GetNatsSysUserCredPath()
-					clientURL := config.GetNatsClientURL()
-					claimspush.PushAccountJWTs(ctx, natsJWTDir, clientURL, credsPath, 10*time.Second)
-				}()
-			}
-		})
-	}
+				return err
+			}},
+			{Name: "pre-reload", Run: func(ctx context.Context, r *reload.Run) error {
+				if r.Action == reload.ActionNone {
+					return nil
+				}
+				err := hookRunner.Run(ctx, hooks.Input{Hook: hooks.PreReload, Causes: r.Names(), Files: r.Files, Action: string(r.Action)})
+				if err != nil {
+					logger.Warn("Reload after change vetoed", logging.KeyCause, r.Names(), "action", r.Action, logging.Err(err))
+					recordReload(r, string(r.Action), metrics.OutcomeVetoed, err)
+					if r.Has(reload.CauseConfig) {
+						// Not loaded: the next event must not be treated as unchanged content.
+						configHash.Forget()
+					}
+				}
+				return err
+			}},
+			reload.ApplyStage(pipelineServer{server: server, restart: requestRestart}, nil),
+			// Report the action applied above: metrics, events, status, the post-reload hook, and post-run work for JWTs.
+			{Name: "post-reload", Run: func(ctx context.Context, r *reload.Run) error {
+				switch {
+				case r.Action == reload.ActionReload && r.Err == nil && r.Outcome == metrics.OutcomeSuccess:
+					metrics.ReloadSucceeded()
+				case r.Action.RestartClass() && r.Err == nil:
+					reason := events.ReasonConfigChange
+					switch {
+					case r.Has(reload.CauseManual):
+						reason = events.ReasonAdmin
+					case mode == "leaf":
+						reason = events.ReasonLeafConfigChange
+						metrics.LeafRestarts.Inc()
+					}
+					events.Emit(events.TypeRestart, map[string]any{"reason": reason, "action": string(r.Action), "causes": r.Names()})
+				}
+				if r.Action != reload.ActionNone && r.Err == nil && r.Has(reload.CauseConfig) {
+					configHash.Loaded()
+				}
+				if r.Err == nil && r.Has(reload.CauseSSL) {
+					// Connections that stay up would keep their TLS material until the next disconnect.
+					natsclient.Reconnect()
+				}
+				if r.HasAny(reload.CauseSSL, reload.CauseCreds, reload.CauseJWT) {
+					// Rescan now rather than on the next tick, so a replaced expired item stops failing /readyz.
+					select {
+					case expiryKick <- struct{}{}:
+					default:
+					}
+				}
+				if r.Action != reload.ActionNone {
+					limiter.Done(time.Now())
+					recordReload(r, string(r.Action), r.Outcome, r.Err)
+					tracker.ReloadDone(r.Names(), string(r.Action), r.Outcome, r.Err)
+					in := hooks.Input{Hook: hooks.PostReload, Causes: r.Names(), Files: r.Files, Action: string(r.Action), Outcome: r.Outcome}
+					if r.Err != nil {
+						in.Error = r.Err.Error()
+					}
+					hookRunner.Run(ctx, in)
+				}
+				switch {
+				case r.Action.RestartClass() && r.Err == nil:
+					r.Post = func(ctx context.Context) { runPostStartTasks(ctx, r.RestartedAt, "restart") }
+				case r.Has(reload.CauseJWT):
+					r.Post = func(ctx context.Context) { runPostStartTasks(ctx, r.RestartedAt, "JWT change") }
+				}
+				return nil
+			}},
+		},
+	})
+	scheduleReload := func(cause reload.Cause, files []string) { pipeline.Trigger(cause, files, false) }
+
+	// One-time reconcile and claims push after startup (e.g. purge accounts removed while process was down, push JWTs
+	// changed while it was down).
+	pipeline.StartPost(func(ctx context.Context) { runPostStartTasks(ctx, time.Time{}, "startup") })
 
-	// Watch server config file; on change trigger coalesced reload
-	go watch.WatchConfigFile(ctx, natsConf, debounce, func() { scheduleReload("config") })
+	// Watch server config file; on change trigger coalesced reload. Watchers report every event (debounce 0); the
+	// pipeline debounces them, bounded by NATS_RELOAD_MAX_WAIT. Watchers that stop are restarted; events may have been
+	// lost then or on a queue overflow, so both trigger a full resync.
+	onOverflow := func() { resyncer.Kick(resync.TriggerOverflow) }
+	onExit := func(error) { resyncer.Kick(resync.TriggerWatcherExit) }
+	watchFile := func(cause reload.Cause, path string) {
+		go watch.Supervise(ctx, string(cause), func(ctx context.Context) error {
+			return watch.WatchConfigFile(ctx, path, 0, func(paths []string) { scheduleReload(cause, paths) }, onOverflow)
+		}, onExit)
+	}
+	watchDir := func(cause reload.Cause, path string) {
+		go watch.Supervise(ctx, string(cause), func(ctx context.Context) error {
+			return watch.WatchDir(ctx, path, 0, func(paths []string) { scheduleReload(cause, paths) }, onOverflow)
+		}, onExit)
+	}
+	watchFile(reload.CauseConfig, natsConf)
 
 	// Watch account config file if it exists
 	if watch.FileExists(natsAccounts) {
-		go watch.WatchConfigFile(ctx, natsAccounts, debounce, func() { scheduleReload("accounts") })
+		watchFile(reload.CauseAccounts, natsAccounts)
 	}
 
 	// Watch SSL directory if it exists
 	if info, err := os.Stat(natsSSLDir); err == nil && info.IsDir() {
-		go watch.WatchDir(ctx, natsSSLDir, debounce, func() { scheduleReload("ssl") })
+		watchDir(reload.CauseSSL, natsSSLDir)
 	}
 
 	// Watch JWT mount directory if it exists; on change sync to JWT dir, coalesced reload/restart, then reconcile and claims push
 	if info, err := os.Stat(natsJWTMountDir); err == nil && info.IsDir() {
-		go watch.WatchDir(ctx, natsJWTMountDir, debounce, func() { scheduleReload("jwt") })
+		watchDir(reload.CauseJWT, natsJWTMountDir)
 	}
 
 	// Watch creds directory if it exists
 	if info, err := os.Stat(natsCredsDir); err == nil && info.IsDir() {
-		go watch.WatchDir(ctx, natsCredsDir, debounce, func() { scheduleReload("creds") })
+		watchDir(reload.CauseCreds, natsCredsDir)
+	}
+
+	// Full resync every NATS_RESYNC_INTERVAL and after watcher overflows and exits: inputs whose content changed unseen
+	// trigger their cause. A JWT mount that differs from the JWT dir triggers a forced jwt run, so the sync goes through
+	// the pipeline like any other; otherwise the reconcile and claims push run here.
+	go resyncer.Run(ctx, config.GetNatsResyncInterval(), func(ctx context.Context, trigger string) {
+		changed := resyncer.Changed()
+		stats, err := jwtcopy.Compare(natsJWTMountDir, natsJWTDir)
+		if err != nil {
+			logger.Error("JWT dir check on resync failed", "trigger", trigger, logging.Err(err))
+			metrics.Resyncs.Inc(trigger, metrics.OutcomeFailure)
+		} else {
+			metrics.Resyncs.Inc(trigger, metrics.OutcomeSuccess)
+		}
+		if stats.Changed() {
+			changed = append(changed, reload.CauseJWT)
+		}
+		if len(changed) > 0 {
+			logger.Warn("Resync found changes the watchers missed", "trigger", trigger, logging.KeyCause, changed)
+		} else {
+			logger.Info("Resync found no missed changes", "trigger", trigger)
+		}
+		for _, c := range changed {
+			if c == reload.CauseJWT {
+				pipeline.Trigger(reload.CauseJWT, nil, true)
+				continue
+			}
+			scheduleReload(c, nil)
+		}
+		// A jwt run does its own reconcile and claims push.
+		if !stats.Changed() {
+			pipeline.StartPost(func(ctx context.Context) { runPostStartTasks(ctx, time.Time{}, "resync") })
+		}
+	})
+
+	// Watchdog: log diagnostics and, unless NATS_WATCHDOG_RESTART=false, restart nats-server through the supervisor loop
+	// below if it stops answering while its process is alive.
+	if interval := config.GetNatsWatchdogInterval(); interval > 0 {
+		wd := watchdog.New(server, watchdog.Options{
+			Interval:    interval,
+			Timeout:     config.GetNatsWatchdogTimeout(),
+			Failures:    config.GetNatsWatchdogFailures(),
+			StartGrace:  config.GetNatsWatchdogStartGrace(),
+			KillTimeout: config.GetNatsWatchdogKillTimeout(),
+			Restart:     config.GetNatsWatchdogRestart(),
+			MonitorPort: config.GetNatsMonitorPort(),
+			ClientURL:   config.GetNatsClientURL(),
+			CredsPath:   config.GetNatsSysUserCredPath(),
+		})
+		go wd.Run(ctx, watchdog.Hooks{
+			Skip: func() bool {
+				snap := tracker.Snapshot()
+				return snap.Restarting || snap.Stopping
+			},
+			Prepare: func() {
+				events.Emit(events.TypeRestart, map[string]any{"reason": events.ReasonWatchdog})
+				watchdogRestart.Store(true)
+				restartRequested.Store(true)
+				tracker.SetRestarting(true)
+			},
+			Abort: func() {
+				watchdogRestart.Store(false)
+				restartRequested.Store(false)
+				tracker.SetRestarting(false)
+			},
+		})
+	}
+
+	// Periodic drift check: compare resolver JWTs with what the server runs (catches missed events and pushes that failed silently).
+	if interval := config.GetNatsDriftCheckInterval(); interval > 0 && config.GetNatsSysUserCredPath() != "" {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				// Snapshot under the sync lock so a concurrent mount sync does not show up as drift; the lookups run
+				// without it, bounded by the interval.
+				jwtSyncMu.Lock()
+				local, err := drift.Snapshot(natsJWTDir)
+				jwtSyncMu.Unlock()
+				if err != nil {
+					logger.Error("Resolver drift check failed", logging.Err(err))
+					continue
+				}
+				checkCtx, cancel := context.WithTimeout(ctx, interval)
+				runDriftCheck(checkCtx, natsJWTDir, local)
+				cancel()
+			}
+		}()
+	}
+
+	// Expiry scan: warn before certificates, account JWTs and creds expire, and report them in metrics and /readyz.
+	// Runs on the interval and after each pipeline run that handled ssl, creds or jwt changes.
+	if interval := config.GetNatsExpiryScanInterval(); interval > 0 {
+		src := expiry.Sources{SSLDir: natsSSLDir, CredsDir: natsCredsDir, JWTDir: natsJWTDir, Config: natsConf}
+		if p := config.GetNatsSysUserCredPath(); p != "" {
+			src.Creds = append(src.Creds, p)
+		}
+		monitor := &expiry.Monitor{Thresholds: config.GetNatsExpiryWarnDays()}
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				monitor.Record(expiry.Scan(src), time.Now(), tracker)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				case <-expiryKick:
+				}
+			}
+		}()
+	}
+
+	// Admin API: operational actions through the same coalescer and locks as the watchers.
+	actions := admin.Actions{
+		Status: func() admin.Status {
+			return admin.Status{
+				Mode:      config.GetNatsServerMode(),
+				Running:   server.Running(),
+				StartedAt: server.StartTime(),
+				Pipeline:  tracker.Snapshot(),
+				Deferred:  pipeline.Deferred(),
+			}
+		},
+		Reload: func(cause string) error {
+			c, err := reload.ParseCause(cause)
+			if err != nil {
+				return err
+			}
+			pipeline.Trigger(c, nil, true)
+			return nil
+		},
+		SyncJWT: func() (jwtcopy.Stats, error) {
+			jwtSyncMu.Lock()
+			stats, err := syncJWT(natsJWTMountDir, natsJWTDir, "admin")
+			jwtSyncMu.Unlock()
+			tracker.SyncDone(err)
+			if err != nil {
+				logger.Error("JWT sync on request failed", logging.Err(err))
+				return stats, err
+			}
+			logger.Info("JWT sync on request", "added", stats.Added, "updated", stats.Updated, "unchanged", stats.Unchanged, "removed", stats.Removed)
+			if stats.Changed() {
+				scheduleReload(reload.CauseJWT, nil)
+			}
+			return stats, nil
+		},
+		Reconcile: func(ctx context.Context, dryRun bool) (jspurge.Reconcile, error) {
+			return runJetStreamReconcile(ctx, natsConf, natsJWTDir, dryRun)
+		},
+		PushClaims: func(ctx context.Context) (claimspush.Result, error) {
+			credsPath := config.GetNatsSysUserCredPath()
+			if credsPath == "" {
+				return claimspush.Result{}, errors.New("NATS_SYS_USER_CRED_PATH is not set")
+			}
+			// Hold the sync lock so a concurrent mount sync does not push half-written JWTs.
+			jwtSyncMu.Lock()
+			defer jwtSyncMu.Unlock()
+			return claimspush.PushAccountJWTs(ctx, natsJWTDir, config.GetNatsClientURL(), credsPath, 10*time.Second)
+		},
+		// A restart is a forced run of the pipeline, so it is serialized with reloads and passes the hooks, TLS check
+		// and rate limit like them.
+		Restart: func() error {
+			pipeline.Request(reload.CauseManual, reload.ActionRestart)
+			return nil
+		},
+		Stop: func() error {
+			set := stopRequested.CompareAndSwap(false, true)
+			tracker.SetStopping(true)
+			if err := server.LameDuck(); err != nil {
+				if set {
+					stopRequested.Store(false)
+					tracker.SetStopping(false)
+				}
+				return err
+			}
+			return nil
+		},
+	}
+	if socket := config.GetNatsAdminSocket(); socket != "" {
+		go func() {
+			if err := admin.ServeUnix(socket, actions); err != nil {
+				logger.Error("Admin API socket failed", "socket", socket, logging.Err(err))
+			}
+		}()
+	}
+	if addr := config.GetNatsAdminAddr(); addr != "" {
+		go func() {
+			if err := admin.ServeTCP(addr, config.GetNatsAdminToken(), actions); err != nil {
+				logger.Error("Admin API listener failed", "addr", addr, logging.Err(err))
+			}
+		}()
+	}
+
+	// Admin micro service on the system account, so a hub operator can manage leaf brokers over NATS.
+	if config.GetNatsMicroEnabled() {
+		if credsPath := config.GetNatsSysUserCredPath(); credsPath == "" {
+			logger.Warn("NATS_MICRO_ENABLED set but NATS_SYS_USER_CRED_PATH unset, not registering admin micro service")
+		} else {
+			go runMicroService(ctx, server, credsPath, actions)
+		}
 	}
 
 	for {
 		err := <-exitCh
-		restartMu.Lock()
-		r := restartRequested
-		if r {
-			restartRequested = false
+		r := restartRequested.Swap(false)
+		if stopRequested.Load() {
+			logger.Info("NATS server stopped on request, exiting")
+			os.Exit(0)
 		}
-		restartMu.Unlock()
 		if r {
-			log.Printf("NATS server stopped for restart, starting again")
+			logger.Info("NATS server stopped for restart, starting again")
+			restartedAt := time.Now()
 			startServer()
+			tracker.SetRestarting(false)
+			if watchdogRestart.Swap(false) {
+				pipeline.StartPost(func(ctx context.Context) { runPostStartTasks(ctx, restartedAt, "watchdog restart") })
+			}
 			continue
 		}
 		if err != nil {
-			log.Printf("NATS server exited: %v", err)
+			metrics.ChildCrashes.Inc()
+			writeTermination("NATS server exited", err)
+			logger.Error("NATS server exited", logging.Err(err))
+			events.Emit(events.TypeCrash, map[string]any{"error": err.Error()})
+			recordCrash()
+			events.Flush(eventsFlushTimeout)
 			os.Exit(1)
 		}
 		os.Exit(0)
 	}
 }
 
-// fileSHA256 returns the SHA256 hash of the file at path, or an error if the file cannot be read.
-func fileSHA256(path string) ([32]byte, error) {
-	data, err := os.ReadFile(path)
+// recordCrash adds the crash to the crash history and emits a crash_loop event when the number of crashes within
+// the crash loop window reaches the threshold.
+func recordCrash() {
+	path := config.GetNatsCrashHistoryPath()
+	if path == "" {
+		return
+	}
+	window := config.GetNatsCrashLoopWindow()
+	n, err := crashloop.Record(path, time.Now(), window)
 	if err != nil {
-		return [32]byte{}, err
+		logger.Warn("Failed to record crash history", "path", path, logging.Err(err))
+		return
+	}
+	if n >= config.GetNatsCrashLoopThreshold() {
+		logger.Error("NATS server is crash looping", "crashes", n, "window", window)
+		events.Emit(events.TypeCrashLoop, map[string]any{"crashes": n, "window": window.String()})
 	}
-	return sha256.Sum256(data), nil
 }
 
+// runMicroService waits for the server, connects to it with the system account and registers the admin micro service.
+// The connection reconnects forever, so the service survives server reloads and restarts.
+func runMicroService(ctx context.Context, server *nats.Server, credsPath string, actions admin.Actions) {
+	if err := server.WaitReady(ctx, time.Time{}, config.GetNatsReadyTimeout()); err != nil {
+		logger.Warn("Server not ready, connecting admin micro service anyway", logging.Err(err))
+	}
+	opts, err := natsclient.Options(credsPath)
+	if err != nil {
+		logger.Error("Admin micro service: client options", logging.Err(err))
+		return
+	}
+	opts = append(opts, natsgo.Name("pot-nats-admin"), natsgo.MaxReconnects(-1), natsgo.RetryOnFailedConnect(true))
+	nc, err := natsgo.Connect(config.GetNatsClientURL(), opts...)
+	if err != nil {
+		logger.Error("Admin micro service: connect failed", logging.Err(err))
+		return
+	}
+	natsclient.Track("admin micro", nc)
+	name := config.GetNatsMicroServerName()
+	for name == "" {
+		// With RetryOnFailedConnect the INFO (and server name) arrives once the first connect succeeds.
+		if name = nc.ConnectedServerName(); name != "" {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			nc.Close()
+			return
+		case <-time.After(time.Second):
+		}
+	}
+	if _, err := admin.ServeMicro(ctx, nc, name, actions); err != nil {
+		logger.Error("Admin micro service: register failed", logging.Err(err))
+		nc.Close()
+	}
+}
+
+// reloadServer sends SIGHUP and, unless NATS_RELOAD_VERIFY_TIMEOUT is 0, waits for nats-server to log the result.
+func reloadServer(server *nats.Server) error {
+	timeout := config.GetNatsReloadVerifyTimeout()
+	if timeout <= 0 {
+		return server.Reload()
+	}
+	return server.ReloadAndVerify(timeout)
+}
+
+// syncJWT runs jwtcopy.SyncMountToJWT and records the result in the JWT sync metrics and a jwt_sync event.
+func syncJWT(mountDir, jwtDir, cause string) (jwtcopy.Stats, error) {
+	stats, err := jwtcopy.SyncMountToJWT(mountDir, jwtDir)
+	data := map[string]any{"cause": cause, "added": stats.Added, "updated": stats.Updated, "unchanged": stats.Unchanged, "removed": stats.Removed}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	events.Emit(events.TypeJWTSync, data)
+	hookIn := hooks.Input{Hook: hooks.PostSync, Causes: []string{cause}, Outcome: metrics.OutcomeSuccess, Data: data}
+	if err != nil {
+		hookIn.Outcome, hookIn.Error = metrics.OutcomeFailure, err.Error()
+	}
+	hookRunner.Run(context.Background(), hookIn)
+	metrics.JWTSyncFiles.Add(float64(stats.Added), "added")
+	metrics.JWTSyncFiles.Add(float64(stats.Updated), "updated")
+	metrics.JWTSyncFiles.Add(float64(stats.Removed), "removed")
+	if err != nil {
+		metrics.JWTSyncs.Inc(metrics.OutcomeFailure)
+	} else {
+		metrics.JWTSyncs.Inc(metrics.OutcomeSuccess)
+	}
+	return stats, err
+}
+
+// inspectCreds checks the creds files in credsDir and the system user creds with validate.InspectCreds against jwtDir,
+// logging failures as errors and warnings as warnings.
+func inspectCreds(credsDir, jwtDir, cause string) {
+	paths := validate.CredsFiles(credsDir)
+	if p := config.GetNatsSysUserCredPath(); p != "" && !slices.Contains(paths, p) {
+		paths = append(paths, p)
+	}
+	now := time.Now()
+	for _, p := range paths {
+		for _, f := range validate.InspectCreds(p, jwtDir, now) {
+			switch f.Severity {
+			case validate.SeverityFail:
+				logger.Error("Creds check failed", logging.KeyCause, cause, "path", f.Path, "error", f.Message)
+			case validate.SeverityWarn:
+				logger.Warn("Creds check warning", logging.KeyCause, cause, "path", f.Path, "warning", f.Message)
+			default:
+				logger.Debug("Creds check passed", logging.KeyCause, cause, "path", f.Path, "detail", f.Message)
+			}
+		}
+	}
+}
+
+// recordReload counts a reload decision once for each active cause and emits it as a reload event.
+func recordReload(r *reload.Run, action, outcome string, err error) {
+	for _, cause := range r.Names() {
+		metrics.Reloads.Inc(cause, outcome)
+	}
+	data := map[string]any{"causes": r.Names(), "action": action, "outcome": outcome}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	events.Emit(events.TypeReload, data)
+}
+
+// pipelineServer is the reload.Server of the pipeline: reloads go to nats-server, restarts to the supervisor loop.
+type pipelineServer struct {
+	server  *nats.Server
+	restart func(lameDuck bool) error
+}
+
+func (s pipelineServer) Reload() error               { return reloadServer(s.server) }
+func (s pipelineServer) Restart(lameDuck bool) error { return s.restart(lameDuck) }
+func (s pipelineServer) Running() bool               { return s.server.Running() }
+
 // runJetStreamReconcile computes accounts with JetStream data but not in the resolver, then purges each via the JetStream Account Purge API.
-// Logs reconciliation start/skip and per-account purge result, inline with existing log style.
-func runJetStreamReconcile(serverConfPath, jwtDir string) {
+// Logs reconciliation start/skip and per-account purge result, inline with existing log style. With dryRun, only the
+// accounts that would be purged are computed.
+func runJetStreamReconcile(ctx context.Context, serverConfPath, jwtDir string, dryRun bool) (jspurge.Reconcile, error) {
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	res := jspurge.Reconcile{DryRun: dryRun}
 	storeDir := config.GetJetStreamStoreDir(serverConfPath)
 	if storeDir == "" {
-		log.Printf("ERROR: JetStream store dir not set or unreadable, skipping account purge reconciliation")
-		return
+		logger.Error("JetStream store dir not set or unreadable, skipping account purge reconciliation")
+		return res, errors.New("JetStream store dir not set or unreadable")
 	}
+	res.StoreDir = storeDir
 	accountsWithJS, err := jspurge.AccountsFromJetStreamStore(storeDir)
 	if err != nil {
-		log.Printf("ERROR: JetStream account reconciliation failed to list store: %v", err)
-		return
+		logger.Error("JetStream account reconciliation failed to list store", "store_dir", storeDir, logging.Err(err))
+		return res, fmt.Errorf("list JetStream store: %w", err)
+	}
+	if !dryRun {
+		recordCompletedPurges(accountsWithJS)
 	}
 	currentResolver, err := jspurge.AccountsFromJWTDir(jwtDir)
 	if err != nil {
-		log.Printf("ERROR: JetStream account reconciliation failed to list JWT dir: %v", err)
-		return
+		logger.Error("JetStream account reconciliation failed to list JWT dir", "jwt_dir", jwtDir, logging.Err(err))
+		return res, fmt.Errorf("list JWT dir: %w", err)
 	}
 	toPurge := jspurge.ToPurge(accountsWithJS, currentResolver)
+	res.ResolverAccounts = len(currentResolver)
+	res.ToPurge = toPurge
 	credsPath := config.GetNatsSysUserCredPath()
 	clientURL := config.GetNatsClientURL()
 
-	log.Printf("JetStream account reconciliation: store_dir=%s, resolver_accounts=%d, to_purge=%d", storeDir, len(currentResolver), len(toPurge))
+	logger.Info("JetStream account reconciliation", "store_dir", storeDir, "resolver_accounts", len(currentResolver), "to_purge", len(toPurge), "dry_run", dryRun)
+	if dryRun {
+		return res, nil
+	}
 	if credsPath == "" {
-		log.Printf("NATS_SYS_USER_CRED_PATH unset, skipping purge API calls")
-		return
+		logger.Info("NATS_SYS_USER_CRED_PATH unset, skipping purge API calls")
+		return res, nil
+	}
+	if len(toPurge) > 0 {
+		if err := hookRunner.Run(ctx, hooks.Input{Hook: hooks.PrePurge, Accounts: toPurge}); err != nil {
+			logger.Warn("JetStream account purge vetoed", "to_purge", len(toPurge), logging.Err(err))
+			return res, err
+		}
 	}
-	ctx := context.Background()
 	for _, account := range toPurge {
-		if err := jspurge.PurgeAccount(ctx, clientURL, credsPath, account); err != nil {
-			log.Printf("ERROR: JetStream account purge failed for %s: %v", account, err)
+		if err := jspurge.PurgeAccount(ctx, clientURL, credsPath, jwtDir, account); err != nil {
+			logger.Error("JetStream account purge failed", logging.KeyAccount, account, logging.Err(err))
+			if res.Failed == nil {
+				res.Failed = make(map[string]string)
+			}
+			res.Failed[account] = err.Error()
+			events.Emit(events.TypePurge, map[string]any{"account": account, "outcome": events.PurgeFailed, "error": err.Error()})
+			continue
+		}
+		logger.Info("JetStream account purge initiated", logging.KeyAccount, account)
+		metrics.PurgesInitiated.Inc()
+		events.Emit(events.TypePurge, map[string]any{"account": account, "outcome": events.PurgeInitiated})
+		res.Initiated = append(res.Initiated, account)
+		pendingPurgesMu.Lock()
+		pendingPurges[account] = struct{}{}
+		pendingPurgesMu.Unlock()
+	}
+	return res, nil
+}
+
+// hookRunner runs the user-defined pipeline hooks; nil (no hooks) unless configured by the wrapper.
+var hookRunner *hooks.Runner
+
+// reconcileMu serializes JetStream reconciliations (post-start tasks and admin requests).
+var reconcileMu sync.Mutex
+
+// Accounts whose purge was initiated but whose JetStream data was still on disk at the last reconcile.
+var (
+	pendingPurgesMu sync.Mutex
+	pendingPurges   = make(map[string]struct{})
+)
+
+// recordCompletedPurges counts pending purges whose account no longer has JetStream data in the store.
+func recordCompletedPurges(accountsWithJS []string) {
+	present := make(map[string]struct{}, len(accountsWithJS))
+	for _, a := range accountsWithJS {
+		present[a] = struct{}{}
+	}
+	pendingPurgesMu.Lock()
+	defer pendingPurgesMu.Unlock()
+	for account := range pendingPurges {
+		if _, ok := present[account]; ok {
 			continue
 		}
-		log.Printf("JetStream account purge initiated for %s", account)
+		delete(pendingPurges, account)
+		metrics.PurgesCompleted.Inc()
+		events.Emit(events.TypePurge, map[string]any{"account": account, "outcome": events.PurgeCompleted})
+		logger.Info("JetStream account purge completed", logging.KeyAccount, account)
+	}
+}
+
+// runDriftCheck compares local, a snapshot of the account JWTs in jwtDir, with the accounts the server runs and logs
+// mismatched, extra and failed accounts. If NATS_DRIFT_REPUSH is set, mismatched accounts are pushed again via claims
+// update. ctx bounds the whole check.
+func runDriftCheck(ctx context.Context, jwtDir string, local map[string][]byte) {
+	credsPath := config.GetNatsSysUserCredPath()
+	clientURL := config.GetNatsClientURL()
+	start := time.Now()
+	report, err := drift.Check(ctx, local, clientURL, credsPath, 10*time.Second)
+	if err != nil {
+		logger.Error("Resolver drift check failed", logging.Err(err))
+		return
+	}
+	for account, reason := range report.Failed {
+		logger.Warn("Resolver drift check: account not checked", logging.KeyAccount, account, "reason", reason)
+	}
+	if !report.Drifted() {
+		logger.Info("Resolver drift check: accounts in sync", "checked", report.Checked, "unloaded", report.Unloaded, "failed", len(report.Failed), logging.KeyDuration, time.Since(start))
+		return
+	}
+	logger.Warn("Resolver drift detected", "checked", report.Checked, "mismatched", report.Mismatched, "extra", report.Extra, "unloaded", report.Unloaded, "failed", len(report.Failed), logging.KeyDuration, time.Since(start))
+	if repush := report.Repush(); len(repush) > 0 && config.GetNatsDriftRepush() {
+		logger.Info("Resolver drift: repushing account JWTs", "accounts", repush)
+		claimspush.PushAccounts(ctx, jwtDir, clientURL, credsPath, repush, 10*time.Second)
 	}
 }
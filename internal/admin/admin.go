@@ -0,0 +1,260 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datasance/nats-server/internal/claimspush"
+	"github.com/datasance/nats-server/internal/jspurge"
+	"github.com/datasance/nats-server/internal/jwtcopy"
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/reload"
+	"github.com/datasance/nats-server/internal/status"
+)
+
+var logger = logging.Component("admin")
+
+// ErrUnknownCause is returned by Actions.Reload for a cause not in reload.Causes.
+var ErrUnknownCause = reload.ErrUnknownCause
+
+// Status is the wrapper state returned by the status action.
+type Status struct {
+	Mode      string          `json:"mode"`
+	Running   bool            `json:"running"`
+	StartedAt time.Time       `json:"started_at,omitempty"`
+	Pipeline  status.Snapshot `json:"pipeline"`
+	// Deferred are the reloads and restarts held back (minimum interval, maintenance window, invalid TLS material).
+	Deferred []reload.Deferral `json:"deferred,omitempty"`
+}
+
+// Actions are the operational actions of a running wrapper. They are implemented by main with the same coalescer
+// and locks the watchers use, and shared by every admin transport.
+type Actions struct {
+	// Status returns the current wrapper and pipeline state.
+	Status func() Status
+	// Reload schedules a reload through the coalescer as if cause had changed, bypassing the unchanged-content check.
+	Reload func(cause string) error
+	// SyncJWT syncs the JWT mount dir to the JWT dir now and schedules a jwt reload if anything changed.
+	SyncJWT func() (jwtcopy.Stats, error)
+	// Reconcile runs the JetStream account reconciliation, or only computes it with dryRun.
+	Reconcile func(ctx context.Context, dryRun bool) (jspurge.Reconcile, error)
+	// PushClaims pushes all account JWTs to the server.
+	PushClaims func(ctx context.Context) (claimspush.Result, error)
+	// Restart queues a restart of nats-server; it returns before the restart.
+	Restart func() error
+	// Stop puts nats-server into lame duck mode; the wrapper exits once the server has shut down.
+	Stop func() error
+}
+
+// Handler serves the admin API:
+//
+//	GET  /v1/status
+//	POST /v1/reload?cause=config
+//	POST /v1/sync-jwt
+//	POST /v1/reconcile?dry_run=true
+//	POST /v1/push-claims
+//	POST /v1/restart
+//	POST /v1/stop
+//
+// Responses are JSON; errors are {"error": "..."}. With a non-empty token, requests must carry
+// "Authorization: Bearer <token>".
+func Handler(a Actions, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.Status())
+	})
+	mux.HandleFunc("POST /v1/reload", func(w http.ResponseWriter, r *http.Request) {
+		cause := r.URL.Query().Get("cause")
+		if err := a.Reload(cause); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrUnknownCause) {
+				code = http.StatusBadRequest
+			}
+			writeError(w, code, err)
+			return
+		}
+		logger.Info("Reload requested", logging.KeyCause, cause)
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "scheduled", "cause": cause})
+	})
+	mux.HandleFunc("POST /v1/sync-jwt", func(w http.ResponseWriter, r *http.Request) {
+		logger.Info("JWT sync requested")
+		stats, err := a.SyncJWT()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, stats)
+	})
+	mux.HandleFunc("POST /v1/reconcile", func(w http.ResponseWriter, r *http.Request) {
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		logger.Info("JetStream reconcile requested", "dry_run", dryRun)
+		res, err := a.Reconcile(r.Context(), dryRun)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("POST /v1/push-claims", func(w http.ResponseWriter, r *http.Request) {
+		logger.Info("Claims push requested")
+		res, err := a.PushClaims(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("POST /v1/restart", func(w http.ResponseWriter, r *http.Request) {
+		logger.Info("Restart requested")
+		if err := a.Restart(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "restarting"})
+	})
+	mux.HandleFunc("POST /v1/stop", func(w http.ResponseWriter, r *http.Request) {
+		logger.Info("Stop (lame duck) requested")
+		if err := a.Stop(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "stopping"})
+	})
+	if token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid admin token"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// ServeUnix serves the admin API without authentication on a unix socket at path, readable and writable by the
+// wrapper user only. A stale socket file left by a previous run is replaced. Blocks until the listener fails.
+func ServeUnix(path string, a Actions) error {
+	// The socket is created in a private (0700) directory and only renamed to path once it is owner-only, so other
+	// users cannot connect in between. A stale socket at path is replaced.
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".admin-socket-")
+	if err != nil {
+		return fmt.Errorf("socket dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "admin.sock")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return err
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		ln.Close()
+		return fmt.Errorf("chmod socket: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return fmt.Errorf("move socket into place: %w", err)
+	}
+	os.Remove(dir)
+	logger.Info("Admin API listening", "socket", path)
+	return http.Serve(ln, Handler(a, ""))
+}
+
+// ServeTCP serves the admin API on addr, requiring token as a bearer token. Blocks until the listener fails.
+func ServeTCP(addr, token string, a Actions) error {
+	if token == "" {
+		return errors.New("admin token is required for the TCP listener")
+	}
+	logger.Info("Admin API listening", "addr", addr)
+	return http.ListenAndServe(addr, Handler(a, token))
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// Client calls the admin API of a running wrapper, over a unix socket or TCP.
+type Client struct {
+	http  *http.Client
+	base  string
+	token string
+}
+
+// NewClient returns a client for the unix socket at socket or, if addr is set, for the TCP listener at addr
+// (host:port) with token.
+func NewClient(socket, addr, token string) *Client {
+	if addr != "" {
+		return &Client{http: &http.Client{Timeout: clientTimeout}, base: "http://" + addr, token: token}
+	}
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{http: &http.Client{Transport: tr, Timeout: clientTimeout}, base: "http://admin"}
+}
+
+// clientTimeout bounds admin requests; reconcile and claims push may take a while with many accounts.
+const clientTimeout = 2 * time.Minute
+
+// Do sends a request for path (e.g. "/v1/status") and returns the response body. Non-2xx responses are returned
+// as errors carrying the API error message.
+func (c *Client) Do(ctx context.Context, method, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Status, e.Error)
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return body, nil
+}
@@ -0,0 +1,130 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/natsclient"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+const (
+	// MicroServiceName is the micro service name; names may not contain dots, so the per-server subject prefix is
+	// a group (see MicroSubjectPrefix).
+	MicroServiceName = "pot-nats"
+	// microVersion is the version of the micro API (endpoints and payloads), not of the wrapper.
+	microVersion = "1.0.0"
+)
+
+// MicroSubjectPrefix returns the endpoint subject prefix for serverName, "pot.nats.<server_name>". Characters
+// not allowed in a subject token are replaced with "_".
+func MicroSubjectPrefix(serverName string) string {
+	return "pot.nats." + natsclient.SubjectToken(serverName)
+}
+
+// microReload is the request payload of the reload endpoint.
+type microReload struct {
+	Cause string `json:"cause"`
+}
+
+// microReconcile is the request payload of the reconcile endpoint.
+type microReconcile struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// ServeMicro registers the admin actions as a NATS micro service on nc (a system account connection), with
+// endpoints status, reload ({"cause":"config"}), sync, reconcile ({"dry_run":true}) and push-claims under
+// MicroSubjectPrefix(serverName). Responses are the same JSON as the admin HTTP API; failures are micro errors
+// with code 400 or 500. Stop the returned service before closing nc.
+func ServeMicro(ctx context.Context, nc *nats.Conn, serverName string, a Actions) (micro.Service, error) {
+	prefix := MicroSubjectPrefix(serverName)
+	svc, err := micro.AddService(nc, micro.Config{
+		Name:        MicroServiceName,
+		Version:     microVersion,
+		Description: "pot-nats wrapper admin actions for " + serverName,
+		Metadata:    map[string]string{"server_name": serverName, "subject_prefix": prefix},
+	})
+	if err != nil {
+		return nil, err
+	}
+	g := svc.AddGroup(prefix)
+	endpoints := map[string]micro.HandlerFunc{
+		"status": func(req micro.Request) {
+			respond(req, a.Status(), nil)
+		},
+		"reload": func(req micro.Request) {
+			var body microReload
+			if err := decode(req, &body); err != nil {
+				req.Error("400", err.Error(), nil)
+				return
+			}
+			err := a.Reload(body.Cause)
+			if err == nil {
+				logger.Info("Reload requested over NATS", logging.KeyCause, body.Cause)
+			}
+			respond(req, map[string]string{"status": "scheduled", "cause": body.Cause}, err)
+		},
+		"sync": func(req micro.Request) {
+			logger.Info("JWT sync requested over NATS")
+			stats, err := a.SyncJWT()
+			respond(req, stats, err)
+		},
+		"reconcile": func(req micro.Request) {
+			var body microReconcile
+			if err := decode(req, &body); err != nil {
+				req.Error("400", err.Error(), nil)
+				return
+			}
+			logger.Info("JetStream reconcile requested over NATS", "dry_run", body.DryRun)
+			res, err := a.Reconcile(ctx, body.DryRun)
+			respond(req, res, err)
+		},
+		"push-claims": func(req micro.Request) {
+			logger.Info("Claims push requested over NATS")
+			res, err := a.PushClaims(ctx)
+			respond(req, res, err)
+		},
+	}
+	for _, name := range []string{"status", "reload", "sync", "reconcile", "push-claims"} {
+		if err := g.AddEndpoint(name, endpoints[name]); err != nil {
+			svc.Stop()
+			return nil, err
+		}
+	}
+	logger.Info("Admin micro service registered", "service", MicroServiceName, "subjects", prefix+".>")
+	return svc, nil
+}
+
+// decode unmarshals the request payload into v; an empty payload leaves v at its zero value.
+func decode(req micro.Request, v any) error {
+	if len(req.Data()) == 0 {
+		return nil
+	}
+	return json.Unmarshal(req.Data(), v)
+}
+
+func respond(req micro.Request, v any, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownCause):
+		req.Error("400", err.Error(), nil)
+	case err != nil:
+		req.Error("500", err.Error(), nil)
+	default:
+		req.RespondJSON(v)
+	}
+}
@@ -0,0 +1,279 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+// Package bootstrap generates the operator-mode identities of a fresh deployment: operator, system account and
+// system user, with the resolver config that points nats-server at them.
+package bootstrap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// Seed file names in Options.KeysDir.
+const (
+	OperatorSeedFile      = "operator.nk"
+	SystemAccountSeedFile = "system-account.nk"
+	SystemUserSeedFile    = "system-user.nk"
+)
+
+// File statuses in Result.
+const (
+	StatusCreated   = "created"
+	StatusUpdated   = "updated"
+	StatusUnchanged = "unchanged"
+)
+
+// Options are where Run writes and how the identities are named.
+type Options struct {
+	// KeysDir holds the seeds (owner-only directory and files).
+	KeysDir string
+	// OperatorJWT is the path of the operator JWT, referenced by the resolver config.
+	OperatorJWT string
+	// ResolverConf is the path of the resolver config fragment to include from the server config.
+	ResolverConf string
+	// JWTDir is the resolver dir (NATS_JWT_DIR); the system account JWT is written there.
+	JWTDir string
+	// CredsPath is the system user creds file (NATS_SYS_USER_CRED_PATH).
+	CredsPath string
+
+	OperatorName      string
+	SystemAccountName string
+	SystemUserName    string
+}
+
+// File is a file Run wrote or kept.
+type File struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+// Result lists the public keys and the files of a Run.
+type Result struct {
+	Operator      string `json:"operator"`
+	SystemAccount string `json:"system_account"`
+	SystemUser    string `json:"system_user"`
+	Files         []File `json:"files"`
+}
+
+// Run creates the operator, system account and system user and writes their JWTs, the system user creds and the
+// resolver config. It is idempotent: existing seeds are reused (never replaced), and JWTs and creds that are still
+// valid for them are kept, so a re-run only fills in what is missing or no longer matches.
+func Run(opts Options) (Result, error) {
+	var res Result
+	if opts.KeysDir == "" || opts.OperatorJWT == "" || opts.ResolverConf == "" || opts.JWTDir == "" || opts.CredsPath == "" {
+		return res, errors.New("keys dir, operator JWT, resolver config, JWT dir and creds path are required")
+	}
+	if err := os.MkdirAll(opts.KeysDir, 0700); err != nil {
+		return res, err
+	}
+	if err := os.Chmod(opts.KeysDir, 0700); err != nil {
+		return res, err
+	}
+
+	operator, err := res.seed(filepath.Join(opts.KeysDir, OperatorSeedFile), nkeys.PrefixByteOperator, nkeys.CreateOperator)
+	if err != nil {
+		return res, err
+	}
+	account, err := res.seed(filepath.Join(opts.KeysDir, SystemAccountSeedFile), nkeys.PrefixByteAccount, nkeys.CreateAccount)
+	if err != nil {
+		return res, err
+	}
+	user, err := res.seed(filepath.Join(opts.KeysDir, SystemUserSeedFile), nkeys.PrefixByteUser, nkeys.CreateUser)
+	if err != nil {
+		return res, err
+	}
+	res.Operator, _ = operator.PublicKey()
+	res.SystemAccount, _ = account.PublicKey()
+	res.SystemUser, _ = user.PublicKey()
+
+	// Operator JWT, self-signed, naming the system account.
+	err = res.keep(opts.OperatorJWT, 0644, func(data []byte) bool {
+		c, err := jwt.DecodeOperatorClaims(string(bytes.TrimSpace(data)))
+		return err == nil && c.Subject == res.Operator && c.SystemAccount == res.SystemAccount
+	}, func() ([]byte, error) {
+		c := jwt.NewOperatorClaims(res.Operator)
+		c.Name = opts.OperatorName
+		c.SystemAccount = res.SystemAccount
+		token, err := c.Encode(operator)
+		return []byte(token), err
+	})
+	if err != nil {
+		return res, err
+	}
+
+	// System account JWT, signed by the operator, in the resolver dir.
+	accountJWT := filepath.Join(opts.JWTDir, res.SystemAccount+".jwt")
+	err = res.keep(accountJWT, 0644, func(data []byte) bool {
+		c, err := jwt.DecodeAccountClaims(string(bytes.TrimSpace(data)))
+		return err == nil && c.Subject == res.SystemAccount && c.Issuer == res.Operator
+	}, func() ([]byte, error) {
+		c := jwt.NewAccountClaims(res.SystemAccount)
+		c.Name = opts.SystemAccountName
+		token, err := c.Encode(operator)
+		return []byte(token), err
+	})
+	if err != nil {
+		return res, err
+	}
+	accountToken, err := os.ReadFile(accountJWT)
+	if err != nil {
+		return res, err
+	}
+
+	// System user creds, signed by the system account.
+	userSeed, _ := user.Seed()
+	err = res.keep(opts.CredsPath, 0600, func(data []byte) bool {
+		token, err := jwt.ParseDecoratedJWT(data)
+		if err != nil {
+			return false
+		}
+		c, err := jwt.DecodeUserClaims(token)
+		if err != nil || c.Subject != res.SystemUser || c.Issuer != res.SystemAccount {
+			return false
+		}
+		seed, err := jwt.ParseDecoratedNKey(data)
+		if err != nil {
+			return false
+		}
+		pub, err := seed.PublicKey()
+		return err == nil && pub == res.SystemUser
+	}, func() ([]byte, error) {
+		c := jwt.NewUserClaims(res.SystemUser)
+		c.Name = opts.SystemUserName
+		token, err := c.Encode(account)
+		if err != nil {
+			return nil, err
+		}
+		return jwt.FormatUserConfig(token, userSeed)
+	})
+	if err != nil {
+		return res, err
+	}
+
+	// Resolver config: a pure function of the above, so it is rewritten only when they change.
+	conf := resolverConf(opts, res.SystemAccount, string(bytes.TrimSpace(accountToken)))
+	err = res.keep(opts.ResolverConf, 0644, func(data []byte) bool {
+		return bytes.Equal(data, conf)
+	}, func() ([]byte, error) {
+		return conf, nil
+	})
+	return res, err
+}
+
+// resolverConf is the server config fragment for the full resolver. The system account is also preloaded, since
+// the JWT mount sync removes JWTs from the resolver dir that are not in the mount.
+func resolverConf(opts Options, systemAccount, accountToken string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Generated by pot-nats bootstrap; include it from the server config.\n")
+	fmt.Fprintf(&b, "operator: %s\n", strconv.Quote(opts.OperatorJWT))
+	fmt.Fprintf(&b, "system_account: %s\n", systemAccount)
+	fmt.Fprintf(&b, "resolver: {\n  type: full\n  dir: %s\n  allow_delete: true\n}\n", strconv.Quote(opts.JWTDir))
+	fmt.Fprintf(&b, "resolver_preload: {\n  %s: %s\n}\n", systemAccount, strconv.Quote(accountToken))
+	return b.Bytes()
+}
+
+// seed loads the seed at path, or creates one with create and writes it owner-only. An existing seed of another
+// kind is an error: replacing it would orphan everything it signed.
+func (r *Result) seed(path string, prefix nkeys.PrefixByte, create func() (nkeys.KeyPair, error)) (nkeys.KeyPair, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		kp, err := nkeys.FromSeed(bytes.TrimSpace(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if got, _, err := nkeys.DecodeSeed(bytes.TrimSpace(data)); err != nil || got != prefix {
+			return nil, fmt.Errorf("%s: seed of kind %s, want %s", path, got, prefix)
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			return nil, err
+		}
+		r.Files = append(r.Files, File{Path: path, Status: StatusUnchanged})
+		return kp, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	kp, err := create()
+	if err != nil {
+		return nil, err
+	}
+	seed, err := kp.Seed()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(path, append(seed, '\n'), 0600); err != nil {
+		return nil, err
+	}
+	r.Files = append(r.Files, File{Path: path, Status: StatusCreated})
+	return kp, nil
+}
+
+// keep leaves the file at path if valid accepts its content, and otherwise writes the output of create.
+func (r *Result) keep(path string, mode os.FileMode, valid func([]byte) bool, create func() ([]byte, error)) error {
+	status := StatusCreated
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil && valid(data):
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+		r.Files = append(r.Files, File{Path: path, Status: StatusUnchanged})
+		return nil
+	case err == nil:
+		status = StatusUpdated
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	data, err = create()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := writeFile(path, data, mode); err != nil {
+		return err
+	}
+	r.Files = append(r.Files, File{Path: path, Status: status})
+	return nil
+}
+
+// writeFile writes data to a temporary file next to path and renames it into place, so watchers never see a
+// partial file.
+func writeFile(path string, data []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -14,48 +14,79 @@ package claimspush
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/datasance/nats-server/internal/events"
 	"github.com/datasance/nats-server/internal/jspurge"
-	"github.com/nats-io/nats.go"
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
+	"github.com/datasance/nats-server/internal/natsclient"
+	"github.com/datasance/nats-server/internal/validate"
 )
 
+var logger = logging.Component("claimspush")
+
 const (
 	claimsUpdateSubject = "$SYS.REQ.CLAIMS.UPDATE"
 	defaultTimeout      = 10 * time.Second
 )
 
+// Result counts the accounts pushed by PushAccounts.
+type Result struct {
+	Pushed int `json:"pushed"`
+	Failed int `json:"failed"`
+}
+
 // PushAccountJWTs lists account JWT files in jwtDir (same convention as jspurge: account-pub-key.jwt),
-// connects to the NATS server at clientURL with credsPath (system account, same as jspurge),
+// connects to the NATS server at clientURL with credsPath (system account, same as jspurge) and the
+// client TLS/nkey/token options from natsclient,
 // and sends a request to $SYS.REQ.CLAIMS.UPDATE with each account's raw JWT. Single server only.
 // If credsPath is empty, returns immediately without error (same as runJetStreamReconcile).
-// Logs per-account success/failure and a summary; non-fatal errors do not stop the process.
-func PushAccountJWTs(ctx context.Context, jwtDir, clientURL, credsPath string, timeout time.Duration) {
+// Logs per-account success/failure and a summary; non-fatal errors do not stop the process. The error is only
+// set when nothing could be pushed (JWT dir unreadable, connect failed); per-account failures are counted in Result.
+func PushAccountJWTs(ctx context.Context, jwtDir, clientURL, credsPath string, timeout time.Duration) (Result, error) {
 	if credsPath == "" {
-		return
+		return Result{}, nil
 	}
-	if timeout <= 0 {
-		timeout = defaultTimeout
-	}
-
 	accounts, err := jspurge.AccountsFromJWTDir(jwtDir)
 	if err != nil {
-		log.Printf("Claims update: failed to list JWT dir %s: %v", jwtDir, err)
-		return
+		logger.Error("Claims update: failed to list JWT dir", "dir", jwtDir, logging.Err(err))
+		return Result{}, fmt.Errorf("list JWT dir: %w", err)
 	}
 	if len(accounts) == 0 {
-		log.Printf("Claims update: no account JWTs in %s", jwtDir)
-		return
+		logger.Info("Claims update: no account JWTs", "dir", jwtDir)
+		return Result{}, nil
+	}
+	return PushAccounts(ctx, jwtDir, clientURL, credsPath, accounts, timeout)
+}
+
+// PushAccounts is like PushAccountJWTs but only pushes the given accounts (each read from jwtDir/account.jwt),
+// e.g. accounts the drift check found mismatched or missing on the server. It refuses to connect if credsPath fails
+// validate.CredsError against jwtDir (e.g. expired, wrong seed, issuer account not in the resolver dir).
+func PushAccounts(ctx context.Context, jwtDir, clientURL, credsPath string, accounts []string, timeout time.Duration) (Result, error) {
+	if credsPath == "" || len(accounts) == 0 {
+		return Result{}, nil
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
 	}
 
-	opts := []nats.Option{nats.UserCredentials(credsPath)}
-	nc, err := nats.Connect(clientURL, opts...)
+	if err := validate.CredsError(credsPath, jwtDir, time.Now()); err != nil {
+		logger.Error("Claims update: refusing to connect with broken system creds", "path", credsPath, logging.Err(err))
+		metrics.ClaimsPushes.Add(float64(len(accounts)), metrics.OutcomeFailure)
+		return Result{Failed: len(accounts)}, fmt.Errorf("system creds: %w", err)
+	}
+
+	start := time.Now()
+	nc, err := natsclient.Connect(clientURL, credsPath)
 	if err != nil {
-		log.Printf("Claims update: failed to connect to %s: %v", clientURL, err)
-		return
+		logger.Error("Claims update: failed to connect", "url", clientURL, logging.Err(err))
+		metrics.ClaimsPushes.Add(float64(len(accounts)), metrics.OutcomeFailure)
+		return Result{Failed: len(accounts)}, fmt.Errorf("connect to %s: %w", clientURL, err)
 	}
 	defer nc.Close()
 
@@ -64,19 +95,50 @@ func PushAccountJWTs(ctx context.Context, jwtDir, clientURL, credsPath string, t
 		jwtPath := filepath.Join(jwtDir, account+".jwt")
 		raw, err := os.ReadFile(jwtPath)
 		if err != nil {
-			log.Printf("Claims update: failed to read %s: %v", jwtPath, err)
+			logger.Error("Claims update: failed to read account JWT", logging.KeyAccount, account, "path", jwtPath, logging.Err(err))
 			failed++
 			continue
 		}
 		reqCtx, cancel := context.WithTimeout(ctx, timeout)
-		_, err = nc.RequestWithContext(reqCtx, claimsUpdateSubject, raw)
+		msg, err := nc.RequestWithContext(reqCtx, claimsUpdateSubject, raw)
 		cancel()
 		if err != nil {
-			log.Printf("Claims update: failed for account %s: %v", account, err)
+			logger.Error("Claims update: failed for account", logging.KeyAccount, account, logging.Err(err))
+			failed++
+			continue
+		}
+		if rejected := updateError(msg.Data); rejected != nil {
+			logger.Error("Claims update: rejected by server", logging.KeyAccount, account, "code", rejected.Code, logging.Err(rejected))
+			events.Emit(events.TypeClaimsRejected, map[string]any{"account": account, "code": rejected.Code, "error": rejected.Description})
 			failed++
 			continue
 		}
 		pushed++
 	}
-	log.Printf("Claims update: pushed %d account JWTs to %s (failed: %d)", pushed, clientURL, failed)
+	metrics.ClaimsPushes.Add(float64(pushed), metrics.OutcomeSuccess)
+	metrics.ClaimsPushes.Add(float64(failed), metrics.OutcomeFailure)
+	logger.Info("Claims update: pushed account JWTs", "url", clientURL, "pushed", pushed, "failed", failed, logging.KeyDuration, time.Since(start))
+	return Result{Pushed: pushed, Failed: failed}, nil
+}
+
+// claimUpdateError is the error of a $SYS.REQ.CLAIMS.UPDATE response, e.g. a JWT not signed by a trusted operator.
+type claimUpdateError struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+func (e *claimUpdateError) Error() string {
+	return e.Description
+}
+
+// updateError returns the error of a claims update response, or nil if the update was accepted or the response
+// cannot be parsed.
+func updateError(data []byte) *claimUpdateError {
+	var resp struct {
+		Error *claimUpdateError `json:"error"`
+	}
+	if json.Unmarshal(data, &resp) != nil {
+		return nil
+	}
+	return resp.Error
 }
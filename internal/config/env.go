@@ -18,31 +18,140 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
-	EnvNatsConf               = "NATS_CONF"
-	EnvNatsAccounts           = "NATS_ACCOUNTS"
-	EnvNatsSSLDir             = "NATS_SSL_DIR"
-	EnvNatsJWTDir             = "NATS_JWT_DIR"
-	EnvNatsJWTMountDir        = "NATS_JWT_MOUNT_DIR"
-	EnvNatsServerMode         = "NATS_SERVER_MODE"
-	EnvNatsCredsDir           = "NATS_CREDS_DIR"
-	EnvNatsServerBin          = "NATS_SERVER_BIN"
-	EnvNatsMonitorPort        = "NATS_MONITOR_PORT"
-	EnvNatsSysUserCredPath    = "NATS_SYS_USER_CRED_PATH"
-	EnvNatsClientURL          = "NATS_CLIENT_URL"
-	EnvNatsJetStreamStoreDir  = "NATS_JETSTREAM_STORE_DIR"
-	DefaultNatsConf           = "/etc/nats/config/server.conf"
-	DefaultNatsAccounts       = "/etc/nats/config/accounts.conf"
-	DefaultNatsSSLDir         = "/etc/nats/certs"
-	DefaultNatsJWTDir         = "/home/runner/nats/jwt"
-	DefaultNatsJWTMountDir    = "/tmp/nats/jwt"
-	DefaultNatsServerMode     = "server"
-	DefaultNatsCredsDir       = "/etc/nats/creds/"
-	DefaultNatsServerBin      = "/home/runner/bin/nats-server"
-	DefaultNatsMonitorPort    = 8222
-	DefaultNatsClientURL      = "nats://127.0.0.1:4222"
+	EnvNatsConf                       = "NATS_CONF"
+	EnvNatsAccounts                   = "NATS_ACCOUNTS"
+	EnvNatsSSLDir                     = "NATS_SSL_DIR"
+	EnvNatsJWTDir                     = "NATS_JWT_DIR"
+	EnvNatsJWTMountDir                = "NATS_JWT_MOUNT_DIR"
+	EnvNatsServerMode                 = "NATS_SERVER_MODE"
+	EnvNatsCredsDir                   = "NATS_CREDS_DIR"
+	EnvNatsServerBin                  = "NATS_SERVER_BIN"
+	EnvNatsMonitorPort                = "NATS_MONITOR_PORT"
+	EnvNatsSysUserCredPath            = "NATS_SYS_USER_CRED_PATH"
+	EnvNatsClientURL                  = "NATS_CLIENT_URL"
+	EnvNatsJetStreamStoreDir          = "NATS_JETSTREAM_STORE_DIR"
+	EnvNatsClientTLS                  = "NATS_CLIENT_TLS"
+	EnvNatsClientTLSCAFile            = "NATS_CLIENT_TLS_CA_FILE"
+	EnvNatsClientTLSCertFile          = "NATS_CLIENT_TLS_CERT_FILE"
+	EnvNatsClientTLSKeyFile           = "NATS_CLIENT_TLS_KEY_FILE"
+	EnvNatsClientTLSServerName        = "NATS_CLIENT_TLS_SERVER_NAME"
+	EnvNatsClientTLSFirst             = "NATS_CLIENT_TLS_FIRST"
+	EnvNatsClientNkeySeedPath         = "NATS_CLIENT_NKEY_SEED_PATH"
+	EnvNatsClientToken                = "NATS_CLIENT_TOKEN"
+	EnvNatsDriftCheckInterval         = "NATS_DRIFT_CHECK_INTERVAL"
+	EnvNatsDriftRepush                = "NATS_DRIFT_REPUSH"
+	EnvNatsWrapperHTTPPort            = "NATS_WRAPPER_HTTP_PORT"
+	EnvNatsExporterEnabled            = "NATS_EXPORTER_ENABLED"
+	EnvNatsExporterEndpoints          = "NATS_EXPORTER_ENDPOINTS"
+	EnvNatsExporterMaxAccounts        = "NATS_EXPORTER_MAX_ACCOUNTS"
+	EnvNatsExporterMaxStreams         = "NATS_EXPORTER_MAX_STREAMS"
+	EnvNatsExporterMaxConnections     = "NATS_EXPORTER_MAX_CONNECTIONS"
+	EnvNatsHealthJetStream            = "NATS_HEALTH_JETSTREAM"
+	EnvNatsWatchdogInterval           = "NATS_WATCHDOG_INTERVAL"
+	EnvNatsWatchdogTimeout            = "NATS_WATCHDOG_TIMEOUT"
+	EnvNatsWatchdogFailures           = "NATS_WATCHDOG_FAILURES"
+	EnvNatsWatchdogStartGrace         = "NATS_WATCHDOG_START_GRACE"
+	EnvNatsWatchdogKillTimeout        = "NATS_WATCHDOG_KILL_TIMEOUT"
+	EnvNatsWatchdogRestart            = "NATS_WATCHDOG_RESTART"
+	EnvNatsReadyTimeout               = "NATS_READY_TIMEOUT"
+	EnvNatsLogFormat                  = "NATS_LOG_FORMAT"
+	EnvNatsLogLevel                   = "NATS_LOG_LEVEL"
+	EnvNatsReloadVerifyTimeout        = "NATS_RELOAD_VERIFY_TIMEOUT"
+	EnvNatsTerminationLogPath         = "NATS_TERMINATION_LOG_PATH"
+	EnvNatsLogRingSize                = "NATS_LOG_RING_SIZE"
+	EnvNatsAdminSocket                = "NATS_ADMIN_SOCKET"
+	EnvNatsAdminAddr                  = "NATS_ADMIN_ADDR"
+	EnvNatsAdminToken                 = "NATS_ADMIN_TOKEN"
+	EnvNatsMicroEnabled               = "NATS_MICRO_ENABLED"
+	EnvNatsMicroServerName            = "NATS_MICRO_SERVER_NAME"
+	EnvNatsEventsEnabled              = "NATS_EVENTS_ENABLED"
+	EnvNatsEventsSubjectPrefix        = "NATS_EVENTS_SUBJECT_PREFIX"
+	EnvNatsEventsCredsPath            = "NATS_EVENTS_CREDS_PATH"
+	EnvNatsEventsStream               = "NATS_EVENTS_STREAM"
+	EnvNatsEventsStreamMaxAge         = "NATS_EVENTS_STREAM_MAX_AGE"
+	EnvNatsWebhookURLs                = "NATS_WEBHOOK_URLS"
+	EnvNatsWebhookSecret              = "NATS_WEBHOOK_SECRET"
+	EnvNatsWebhookEvents              = "NATS_WEBHOOK_EVENTS"
+	EnvNatsWebhookTimeout             = "NATS_WEBHOOK_TIMEOUT"
+	EnvNatsWebhookMaxRetries          = "NATS_WEBHOOK_MAX_RETRIES"
+	EnvNatsWebhookQueueSize           = "NATS_WEBHOOK_QUEUE_SIZE"
+	EnvNatsCrashHistoryPath           = "NATS_CRASH_HISTORY_PATH"
+	EnvNatsCrashLoopWindow            = "NATS_CRASH_LOOP_WINDOW"
+	EnvNatsCrashLoopThreshold         = "NATS_CRASH_LOOP_THRESHOLD"
+	EnvNatsHookPreValidate            = "NATS_HOOK_PRE_VALIDATE"
+	EnvNatsHookPostSync               = "NATS_HOOK_POST_SYNC"
+	EnvNatsHookPreReload              = "NATS_HOOK_PRE_RELOAD"
+	EnvNatsHookPostReload             = "NATS_HOOK_POST_RELOAD"
+	EnvNatsHookPrePurge               = "NATS_HOOK_PRE_PURGE"
+	EnvNatsHookTimeout                = "NATS_HOOK_TIMEOUT"
+	EnvNatsReloadMaxWait              = "NATS_RELOAD_MAX_WAIT"
+	EnvNatsReloadPolicy               = "NATS_RELOAD_POLICY"
+	EnvNatsReloadMinInterval          = "NATS_RELOAD_MIN_INTERVAL"
+	EnvNatsMaintenanceWindows         = "NATS_MAINTENANCE_WINDOWS"
+	EnvNatsMaintenanceUrgent          = "NATS_MAINTENANCE_URGENT"
+	EnvNatsResyncInterval             = "NATS_RESYNC_INTERVAL"
+	EnvNatsRequiredInputs             = "NATS_REQUIRED_INPUTS"
+	EnvNatsInputWaitTimeout           = "NATS_INPUT_WAIT_TIMEOUT"
+	EnvNatsTLSCheck                   = "NATS_TLS_CHECK"
+	EnvNatsTLSSettleTimeout           = "NATS_TLS_SETTLE_TIMEOUT"
+	EnvNatsExpiryScanInterval         = "NATS_EXPIRY_SCAN_INTERVAL"
+	EnvNatsExpiryWarnDays             = "NATS_EXPIRY_WARN_DAYS"
+	EnvNatsExpiryFailReadiness        = "NATS_EXPIRY_FAIL_READINESS"
+	EnvNatsKeysDir                    = "NATS_KEYS_DIR"
+	DefaultNatsConf                   = "/etc/nats/config/server.conf"
+	DefaultNatsAccounts               = "/etc/nats/config/accounts.conf"
+	DefaultNatsSSLDir                 = "/etc/nats/certs"
+	DefaultNatsJWTDir                 = "/home/runner/nats/jwt"
+	DefaultNatsJWTMountDir            = "/tmp/nats/jwt"
+	DefaultNatsServerMode             = "server"
+	DefaultNatsCredsDir               = "/etc/nats/creds/"
+	DefaultNatsServerBin              = "/home/runner/bin/nats-server"
+	DefaultNatsMonitorPort            = 8222
+	DefaultNatsClientURL              = "nats://127.0.0.1:4222"
+	DefaultNatsClientTLSCAFile        = "ca.crt"
+	DefaultNatsClientTLSCertFile      = "tls.crt"
+	DefaultNatsClientTLSKeyFile       = "tls.key"
+	DefaultNatsDriftCheckInterval     = 5 * time.Minute
+	DefaultNatsWrapperHTTPPort        = 7777
+	DefaultNatsExporterEndpoints      = "varz,connz,jsz,leafz,routez,accountz"
+	DefaultNatsExporterMaxAccounts    = 100
+	DefaultNatsExporterMaxStreams     = 500
+	DefaultNatsExporterMaxConnections = 1024
+	DefaultNatsWatchdogInterval       = 15 * time.Second
+	DefaultNatsWatchdogTimeout        = 5 * time.Second
+	DefaultNatsWatchdogFailures       = 4
+	DefaultNatsWatchdogStartGrace     = time.Minute
+	DefaultNatsWatchdogKillTimeout    = 10 * time.Second
+	DefaultNatsReadyTimeout           = time.Minute
+	DefaultNatsLogFormat              = "text"
+	DefaultNatsLogLevel               = "info"
+	DefaultNatsReloadVerifyTimeout    = 10 * time.Second
+	DefaultNatsTerminationLogPath     = "/dev/termination-log"
+	DefaultNatsLogRingSize            = 200
+	DefaultNatsAdminSocket            = "/home/runner/nats/admin.sock"
+	DefaultNatsEventsSubjectPrefix    = "$POT.NATS.EVENT"
+	DefaultNatsEventsStreamMaxAge     = 7 * 24 * time.Hour
+	DefaultNatsWebhookEvents          = "reload_failed,leaf_restarted,crash_loop,purge_initiated,jwt_rejected"
+	DefaultNatsWebhookTimeout         = 5 * time.Second
+	DefaultNatsWebhookMaxRetries      = 5
+	DefaultNatsWebhookQueueSize       = 256
+	DefaultNatsCrashHistoryPath       = "/home/runner/nats/crash-history"
+	DefaultNatsCrashLoopWindow        = 10 * time.Minute
+	DefaultNatsCrashLoopThreshold     = 3
+	DefaultNatsHookTimeout            = 30 * time.Second
+	DefaultNatsReloadMaxWait          = 10 * time.Second
+	DefaultNatsReloadMinInterval      = 0 * time.Second
+	DefaultNatsMaintenanceUrgent      = "ssl"
+	DefaultNatsResyncInterval         = 10 * time.Minute
+	DefaultNatsInputWaitTimeout       = 30 * time.Second
+	DefaultNatsTLSSettleTimeout       = 30 * time.Second
+	DefaultNatsExpiryScanInterval     = time.Hour
+	DefaultNatsExpiryWarnDays         = "30,7,1"
+	DefaultNatsKeysDir                = "/home/runner/nats/keys"
 )
 
 // GetNatsConf returns the server config file path from NATS_CONF, or DefaultNatsConf if unset.
@@ -86,7 +195,8 @@ func GetNatsJWTMountDir() string {
 }
 
 // GetNatsServerMode returns the server mode from NATS_SERVER_MODE, or DefaultNatsServerMode if unset.
-// Returned value is trimmed and lowercased for comparison (e.g. "server", "leaf").
+// Returned value is trimmed and lowercased for comparison (e.g. "server", "leaf"). The mode selects the preset of the
+// reload policy.
 func GetNatsServerMode() string {
 	s := strings.TrimSpace(os.Getenv(EnvNatsServerMode))
 	if s == "" {
@@ -95,6 +205,25 @@ func GetNatsServerMode() string {
 	return strings.ToLower(s)
 }
 
+// GetNatsReloadPolicy returns the overrides of the mode's reload policy from NATS_RELOAD_POLICY, a comma-separated
+// list of cause=action (e.g. "config=lame-duck-restart,creds=ignore"). Returns empty string (preset only) if unset.
+func GetNatsReloadPolicy() string {
+	return os.Getenv(EnvNatsReloadPolicy)
+}
+
+// GetNatsRequiredInputs returns the inputs the wrapper waits for before starting nats-server, from
+// NATS_REQUIRED_INPUTS, a comma-separated list of kind:path (file:<path>, dir:<path>, jwt:<dir>, tls:<cert>:<key> or
+// tls:<dir>). Returns empty string if unset; NATS_CONF is always required.
+func GetNatsRequiredInputs() string {
+	return os.Getenv(EnvNatsRequiredInputs)
+}
+
+// GetNatsInputWaitTimeout returns how long the wrapper waits for the required inputs at startup, from
+// NATS_INPUT_WAIT_TIMEOUT, or DefaultNatsInputWaitTimeout (30s) if unset or invalid. 0 waits indefinitely.
+func GetNatsInputWaitTimeout() time.Duration {
+	return lookupDuration(EnvNatsInputWaitTimeout, DefaultNatsInputWaitTimeout)
+}
+
 // GetNatsCredsDir returns the creds directory from NATS_CREDS_DIR, or DefaultNatsCredsDir if unset.
 func GetNatsCredsDir() string {
 	if p := os.Getenv(EnvNatsCredsDir); p != "" {
@@ -125,6 +254,455 @@ func GetNatsMonitorPort() int {
 	return port
 }
 
+// GetNatsWrapperHTTPPort returns the port of the wrapper's own HTTP server (/metrics) from NATS_WRAPPER_HTTP_PORT,
+// or DefaultNatsWrapperHTTPPort (7777) if unset or invalid. Set to 0 to disable.
+func GetNatsWrapperHTTPPort() int {
+	s := os.Getenv(EnvNatsWrapperHTTPPort)
+	if s == "" {
+		return DefaultNatsWrapperHTTPPort
+	}
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 0 || port > 65535 {
+		return DefaultNatsWrapperHTTPPort
+	}
+	return port
+}
+
+// GetNatsExporterEnabled reports whether the wrapper re-exposes nats-server monitoring endpoints as Prometheus
+// metrics on its /metrics, from NATS_EXPORTER_ENABLED (default true). Requires NATS_MONITOR_PORT > 0.
+func GetNatsExporterEnabled() bool {
+	if v, ok := lookupBool(EnvNatsExporterEnabled); ok {
+		return v
+	}
+	return true
+}
+
+// GetNatsExporterEndpoints returns the monitoring endpoints scraped by the exporter from NATS_EXPORTER_ENDPOINTS
+// (comma-separated, e.g. "varz,jsz"), or DefaultNatsExporterEndpoints if unset.
+func GetNatsExporterEndpoints() []string {
+	s := os.Getenv(EnvNatsExporterEndpoints)
+	if strings.TrimSpace(s) == "" {
+		s = DefaultNatsExporterEndpoints
+	}
+	var out []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// GetNatsExporterMaxAccounts returns the maximum number of account label values per exported metric from
+// NATS_EXPORTER_MAX_ACCOUNTS, or DefaultNatsExporterMaxAccounts (100) if unset or invalid.
+func GetNatsExporterMaxAccounts() int {
+	return lookupPositiveInt(EnvNatsExporterMaxAccounts, DefaultNatsExporterMaxAccounts)
+}
+
+// GetNatsExporterMaxStreams returns the maximum number of streams exported with account and stream labels from
+// NATS_EXPORTER_MAX_STREAMS, or DefaultNatsExporterMaxStreams (500) if unset or invalid.
+func GetNatsExporterMaxStreams() int {
+	return lookupPositiveInt(EnvNatsExporterMaxStreams, DefaultNatsExporterMaxStreams)
+}
+
+// GetNatsExporterMaxConnections returns the maximum number of connections, leafnodes and routes fetched per scrape
+// from NATS_EXPORTER_MAX_CONNECTIONS, or DefaultNatsExporterMaxConnections (1024) if unset or invalid.
+func GetNatsExporterMaxConnections() int {
+	return lookupPositiveInt(EnvNatsExporterMaxConnections, DefaultNatsExporterMaxConnections)
+}
+
+// GetNatsHealthJetStream reports whether /readyz runs nats-server's full JetStream health checks (every stream and
+// consumer current), from NATS_HEALTH_JETSTREAM. Default false: /healthz is queried with js-enabled-only=true.
+func GetNatsHealthJetStream() bool {
+	v, _ := lookupBool(EnvNatsHealthJetStream)
+	return v
+}
+
+// GetNatsWatchdogInterval returns the interval between watchdog probes of nats-server from NATS_WATCHDOG_INTERVAL,
+// or DefaultNatsWatchdogInterval (15s) if unset or invalid. Set to 0 to disable the watchdog.
+func GetNatsWatchdogInterval() time.Duration {
+	return lookupDuration(EnvNatsWatchdogInterval, DefaultNatsWatchdogInterval)
+}
+
+// GetNatsWatchdogTimeout returns the timeout of each watchdog probe from NATS_WATCHDOG_TIMEOUT,
+// or DefaultNatsWatchdogTimeout (5s) if unset or invalid.
+func GetNatsWatchdogTimeout() time.Duration {
+	if d := lookupDuration(EnvNatsWatchdogTimeout, DefaultNatsWatchdogTimeout); d > 0 {
+		return d
+	}
+	return DefaultNatsWatchdogTimeout
+}
+
+// GetNatsWatchdogFailures returns the number of consecutive failed probes after which nats-server is considered
+// wedged and restarted, from NATS_WATCHDOG_FAILURES, or DefaultNatsWatchdogFailures (4) if unset or invalid.
+func GetNatsWatchdogFailures() int {
+	return lookupPositiveInt(EnvNatsWatchdogFailures, DefaultNatsWatchdogFailures)
+}
+
+// GetNatsWatchdogStartGrace returns how long after a (re)start the watchdog waits before probing, from
+// NATS_WATCHDOG_START_GRACE, or DefaultNatsWatchdogStartGrace (1m) if unset or invalid.
+func GetNatsWatchdogStartGrace() time.Duration {
+	return lookupDuration(EnvNatsWatchdogStartGrace, DefaultNatsWatchdogStartGrace)
+}
+
+// GetNatsWatchdogKillTimeout returns how long the watchdog waits for nats-server to exit after SIGQUIT before
+// sending SIGKILL, from NATS_WATCHDOG_KILL_TIMEOUT, or DefaultNatsWatchdogKillTimeout (10s) if unset or invalid.
+func GetNatsWatchdogKillTimeout() time.Duration {
+	if d := lookupDuration(EnvNatsWatchdogKillTimeout, DefaultNatsWatchdogKillTimeout); d > 0 {
+		return d
+	}
+	return DefaultNatsWatchdogKillTimeout
+}
+
+// GetNatsWatchdogRestart reports whether the watchdog restarts a wedged nats-server, from NATS_WATCHDOG_RESTART
+// (default true; false only logs diagnostics).
+func GetNatsWatchdogRestart() bool {
+	if v, ok := lookupBool(EnvNatsWatchdogRestart); ok {
+		return v
+	}
+	return true
+}
+
+// GetNatsReadyTimeout returns how long post-start and post-reload tasks (reconcile, claims push) wait for
+// nats-server to report healthy, from NATS_READY_TIMEOUT, or DefaultNatsReadyTimeout (1m) if unset or invalid.
+func GetNatsReadyTimeout() time.Duration {
+	if d := lookupDuration(EnvNatsReadyTimeout, DefaultNatsReadyTimeout); d > 0 {
+		return d
+	}
+	return DefaultNatsReadyTimeout
+}
+
+// GetNatsLogFormat returns the wrapper log format from NATS_LOG_FORMAT ("text" or "json"), or DefaultNatsLogFormat if unset.
+func GetNatsLogFormat() string {
+	if s := strings.TrimSpace(os.Getenv(EnvNatsLogFormat)); s != "" {
+		return strings.ToLower(s)
+	}
+	return DefaultNatsLogFormat
+}
+
+// GetNatsLogLevel returns the wrapper log level from NATS_LOG_LEVEL ("debug", "info", "warn", "error"),
+// or DefaultNatsLogLevel if unset.
+func GetNatsLogLevel() string {
+	if s := strings.TrimSpace(os.Getenv(EnvNatsLogLevel)); s != "" {
+		return strings.ToLower(s)
+	}
+	return DefaultNatsLogLevel
+}
+
+// GetNatsReloadVerifyTimeout returns how long the wrapper waits for nats-server to log the result of a SIGHUP
+// reload, from NATS_RELOAD_VERIFY_TIMEOUT, or DefaultNatsReloadVerifyTimeout (10s) if unset or invalid.
+// Set to 0 to send SIGHUP without waiting for the result.
+func GetNatsReloadVerifyTimeout() time.Duration {
+	return lookupDuration(EnvNatsReloadVerifyTimeout, DefaultNatsReloadVerifyTimeout)
+}
+
+// GetNatsReloadMaxWait returns the longest time changes wait for a reload while more changes keep arriving within
+// the debounce, from NATS_RELOAD_MAX_WAIT, or DefaultNatsReloadMaxWait (10s) if unset or invalid. 0 disables the bound.
+func GetNatsReloadMaxWait() time.Duration {
+	return lookupDuration(EnvNatsReloadMaxWait, DefaultNatsReloadMaxWait)
+}
+
+// GetNatsExpiryScanInterval returns how often the wrapper scans certificates, JWTs and creds for expiry, from
+// NATS_EXPIRY_SCAN_INTERVAL, or DefaultNatsExpiryScanInterval (1h) if unset or invalid. 0 disables the scanner.
+func GetNatsExpiryScanInterval() time.Duration {
+	return lookupDuration(EnvNatsExpiryScanInterval, DefaultNatsExpiryScanInterval)
+}
+
+// GetNatsExpiryWarnDays returns the expiry warning thresholds from NATS_EXPIRY_WARN_DAYS, comma-separated days before
+// expiry, or DefaultNatsExpiryWarnDays (30,7,1) if unset or invalid.
+func GetNatsExpiryWarnDays() []time.Duration {
+	parse := func(s string) []time.Duration {
+		var out []time.Duration
+		for _, f := range strings.Split(s, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil || n <= 0 {
+				return nil
+			}
+			out = append(out, time.Duration(n)*24*time.Hour)
+		}
+		return out
+	}
+	if out := parse(os.Getenv(EnvNatsExpiryWarnDays)); out != nil {
+		return out
+	}
+	return parse(DefaultNatsExpiryWarnDays)
+}
+
+// GetNatsExpiryFailReadiness reports whether an expired certificate, JWT or creds fails /readyz, from
+// NATS_EXPIRY_FAIL_READINESS (default false: expiry is only reported in /readyz).
+func GetNatsExpiryFailReadiness() bool {
+	v, _ := lookupBool(EnvNatsExpiryFailReadiness)
+	return v
+}
+
+// GetNatsKeysDir returns the directory of the operator, system account and system user seeds written by
+// "pot-nats bootstrap" from NATS_KEYS_DIR, or DefaultNatsKeysDir (/home/runner/nats/keys) if unset.
+func GetNatsKeysDir() string {
+	if p := os.Getenv(EnvNatsKeysDir); p != "" {
+		return p
+	}
+	return DefaultNatsKeysDir
+}
+
+// GetNatsResyncInterval returns how often the wrapper resyncs all inputs in full (hash inputs, JWT sync, reconcile,
+// claims push) to heal missed file events, from NATS_RESYNC_INTERVAL, or DefaultNatsResyncInterval (10m) if unset or
+// invalid. 0 disables the periodic resync; watcher overflows and exits still trigger one.
+func GetNatsResyncInterval() time.Duration {
+	return lookupDuration(EnvNatsResyncInterval, DefaultNatsResyncInterval)
+}
+
+// GetNatsTLSCheck reports whether the wrapper validates the TLS material referenced by the server config before a
+// reload or restart, from NATS_TLS_CHECK (default true).
+func GetNatsTLSCheck() bool {
+	if v, ok := lookupBool(EnvNatsTLSCheck); ok {
+		return v
+	}
+	return true
+}
+
+// GetNatsTLSSettleTimeout returns how long a reload waits for inconsistent TLS material (e.g. a certificate rotated
+// before its key) to become valid before it is reported as failed, from NATS_TLS_SETTLE_TIMEOUT, or
+// DefaultNatsTLSSettleTimeout (30s) if unset or invalid. 0 fails at once.
+func GetNatsTLSSettleTimeout() time.Duration {
+	return lookupDuration(EnvNatsTLSSettleTimeout, DefaultNatsTLSSettleTimeout)
+}
+
+// GetNatsReloadMinInterval returns the minimum time between two reloads or restarts, from NATS_RELOAD_MIN_INTERVAL,
+// or DefaultNatsReloadMinInterval (0, disabled) if unset or invalid. Changes arriving sooner are merged and applied
+// once the interval has passed.
+func GetNatsReloadMinInterval() time.Duration {
+	return lookupDuration(EnvNatsReloadMinInterval, DefaultNatsReloadMinInterval)
+}
+
+// GetNatsMaintenanceWindows returns the maintenance windows for restarts from NATS_MAINTENANCE_WINDOWS,
+// semicolon-separated "<cron expression> <duration>" entries (e.g. "0 2 * * * 2h"). Returns empty string (restart any
+// time) if unset.
+func GetNatsMaintenanceWindows() string {
+	return os.Getenv(EnvNatsMaintenanceWindows)
+}
+
+// GetNatsMaintenanceUrgent returns the causes whose restarts never wait for a maintenance window, from
+// NATS_MAINTENANCE_URGENT (comma-separated), or DefaultNatsMaintenanceUrgent (ssl) if unset. Set to an empty value to
+// hold every restart.
+func GetNatsMaintenanceUrgent() []string {
+	s, ok := os.LookupEnv(EnvNatsMaintenanceUrgent)
+	if !ok {
+		s = DefaultNatsMaintenanceUrgent
+	}
+	var out []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// GetNatsTerminationLogPath returns the file the crash summary is written to on fatal exit, from
+// NATS_TERMINATION_LOG_PATH, or DefaultNatsTerminationLogPath (/dev/termination-log) if unset.
+// Set to an empty value to disable the termination message.
+func GetNatsTerminationLogPath() string {
+	if p, ok := os.LookupEnv(EnvNatsTerminationLogPath); ok {
+		return strings.TrimSpace(p)
+	}
+	return DefaultNatsTerminationLogPath
+}
+
+// GetNatsLogRingSize returns the number of recent log records kept in memory for the termination message, from
+// NATS_LOG_RING_SIZE, or DefaultNatsLogRingSize (200) if unset or invalid.
+func GetNatsLogRingSize() int {
+	return lookupPositiveInt(EnvNatsLogRingSize, DefaultNatsLogRingSize)
+}
+
+// GetNatsAdminSocket returns the unix socket path of the admin API from NATS_ADMIN_SOCKET, or
+// DefaultNatsAdminSocket (/home/runner/nats/admin.sock) if unset. Set to an empty value to disable the socket.
+func GetNatsAdminSocket() string {
+	if p, ok := os.LookupEnv(EnvNatsAdminSocket); ok {
+		return strings.TrimSpace(p)
+	}
+	return DefaultNatsAdminSocket
+}
+
+// GetNatsAdminAddr returns the TCP listen address of the admin API (e.g. ":7778") from NATS_ADMIN_ADDR.
+// Returns empty string (TCP listener disabled) if unset.
+func GetNatsAdminAddr() string {
+	return strings.TrimSpace(os.Getenv(EnvNatsAdminAddr))
+}
+
+// GetNatsAdminToken returns the bearer token required on the admin TCP listener from NATS_ADMIN_TOKEN.
+// Returns empty string if unset; the TCP listener is not started without a token.
+func GetNatsAdminToken() string {
+	return strings.TrimSpace(os.Getenv(EnvNatsAdminToken))
+}
+
+// GetNatsMicroEnabled reports whether the admin actions are also served as a NATS micro service on the system
+// account, from NATS_MICRO_ENABLED. Default false.
+func GetNatsMicroEnabled() bool {
+	v, _ := lookupBool(EnvNatsMicroEnabled)
+	return v
+}
+
+// GetNatsMicroServerName returns the server name used in the micro service subjects from NATS_MICRO_SERVER_NAME.
+// Returns empty string if unset; the name the server reports on connect (server_name) is used then.
+func GetNatsMicroServerName() string {
+	return strings.TrimSpace(os.Getenv(EnvNatsMicroServerName))
+}
+
+// GetNatsEventsEnabled reports whether wrapper lifecycle events are published to NATS, from NATS_EVENTS_ENABLED.
+// Default false.
+func GetNatsEventsEnabled() bool {
+	v, _ := lookupBool(EnvNatsEventsEnabled)
+	return v
+}
+
+// GetNatsEventsSubjectPrefix returns the subject prefix of lifecycle events from NATS_EVENTS_SUBJECT_PREFIX, or
+// DefaultNatsEventsSubjectPrefix ($POT.NATS.EVENT) if unset. Events are published to <prefix>.<server_name>.<type>.
+func GetNatsEventsSubjectPrefix() string {
+	if s := strings.Trim(strings.TrimSpace(os.Getenv(EnvNatsEventsSubjectPrefix)), "."); s != "" {
+		return s
+	}
+	return DefaultNatsEventsSubjectPrefix
+}
+
+// GetNatsEventsCredsPath returns the credentials used to publish lifecycle events from NATS_EVENTS_CREDS_PATH, which
+// selects the account events are published in. If the value is not an absolute path, it is resolved relative to
+// NATS_CREDS_DIR. Defaults to the system account user (GetNatsSysUserCredPath) if unset.
+func GetNatsEventsCredsPath() string {
+	p := os.Getenv(EnvNatsEventsCredsPath)
+	if p == "" {
+		return GetNatsSysUserCredPath()
+	}
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(GetNatsCredsDir(), p)
+}
+
+// GetNatsEventsStream returns the JetStream stream retaining lifecycle events from NATS_EVENTS_STREAM.
+// Returns empty string (no stream) if unset.
+func GetNatsEventsStream() string {
+	return strings.TrimSpace(os.Getenv(EnvNatsEventsStream))
+}
+
+// GetNatsEventsStreamMaxAge returns how long the events stream retains events from NATS_EVENTS_STREAM_MAX_AGE,
+// or DefaultNatsEventsStreamMaxAge (7 days) if unset or invalid. 0 keeps events until limits of the account apply.
+func GetNatsEventsStreamMaxAge() time.Duration {
+	return lookupDuration(EnvNatsEventsStreamMaxAge, DefaultNatsEventsStreamMaxAge)
+}
+
+// GetNatsWebhookURLs returns the webhook receivers from NATS_WEBHOOK_URLS (comma-separated). Returns nil (webhooks
+// disabled) if unset.
+func GetNatsWebhookURLs() []string {
+	var out []string
+	for _, u := range strings.Split(os.Getenv(EnvNatsWebhookURLs), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// GetNatsWebhookSecret returns the HMAC-SHA256 signing secret of webhook requests from NATS_WEBHOOK_SECRET.
+// Returns empty string (requests are not signed) if unset.
+func GetNatsWebhookSecret() string {
+	return os.Getenv(EnvNatsWebhookSecret)
+}
+
+// GetNatsWebhookEvents returns the event kinds sent to webhooks from NATS_WEBHOOK_EVENTS (comma-separated), or
+// DefaultNatsWebhookEvents (all kinds) if unset.
+func GetNatsWebhookEvents() []string {
+	s := os.Getenv(EnvNatsWebhookEvents)
+	if strings.TrimSpace(s) == "" {
+		s = DefaultNatsWebhookEvents
+	}
+	var out []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// GetNatsWebhookTimeout returns the timeout of each webhook request from NATS_WEBHOOK_TIMEOUT, or
+// DefaultNatsWebhookTimeout (5s) if unset, invalid or zero.
+func GetNatsWebhookTimeout() time.Duration {
+	if d := lookupDuration(EnvNatsWebhookTimeout, DefaultNatsWebhookTimeout); d > 0 {
+		return d
+	}
+	return DefaultNatsWebhookTimeout
+}
+
+// GetNatsWebhookMaxRetries returns how often a failed webhook request is retried from NATS_WEBHOOK_MAX_RETRIES,
+// or DefaultNatsWebhookMaxRetries (5) if unset or invalid. 0 disables retries.
+func GetNatsWebhookMaxRetries() int {
+	n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(EnvNatsWebhookMaxRetries)))
+	if err != nil || n < 0 {
+		return DefaultNatsWebhookMaxRetries
+	}
+	return n
+}
+
+// GetNatsWebhookQueueSize returns the number of events waiting for webhook delivery before further events are
+// dropped from NATS_WEBHOOK_QUEUE_SIZE, or DefaultNatsWebhookQueueSize (256) if unset or invalid.
+func GetNatsWebhookQueueSize() int {
+	return lookupPositiveInt(EnvNatsWebhookQueueSize, DefaultNatsWebhookQueueSize)
+}
+
+// GetNatsCrashHistoryPath returns the file recording recent crashes for crash loop detection from
+// NATS_CRASH_HISTORY_PATH, or DefaultNatsCrashHistoryPath (/home/runner/nats/crash-history) if unset.
+// Set to an empty value to disable crash loop detection.
+func GetNatsCrashHistoryPath() string {
+	if p, ok := os.LookupEnv(EnvNatsCrashHistoryPath); ok {
+		return p
+	}
+	return DefaultNatsCrashHistoryPath
+}
+
+// GetNatsCrashLoopWindow returns the window in which repeated crashes are a crash loop from
+// NATS_CRASH_LOOP_WINDOW, or DefaultNatsCrashLoopWindow (10m) if unset or invalid.
+func GetNatsCrashLoopWindow() time.Duration {
+	if d := lookupDuration(EnvNatsCrashLoopWindow, DefaultNatsCrashLoopWindow); d > 0 {
+		return d
+	}
+	return DefaultNatsCrashLoopWindow
+}
+
+// GetNatsCrashLoopThreshold returns the number of crashes within the window that make a crash loop from
+// NATS_CRASH_LOOP_THRESHOLD, or DefaultNatsCrashLoopThreshold (3) if unset or invalid.
+func GetNatsCrashLoopThreshold() int {
+	return lookupPositiveInt(EnvNatsCrashLoopThreshold, DefaultNatsCrashLoopThreshold)
+}
+
+// GetNatsHookCommands returns the hook commands by hook point ("pre-validate", "post-sync", "pre-reload",
+// "post-reload", "pre-purge") from NATS_HOOK_PRE_VALIDATE, NATS_HOOK_POST_SYNC, NATS_HOOK_PRE_RELOAD,
+// NATS_HOOK_POST_RELOAD and NATS_HOOK_PRE_PURGE. Each value is split on whitespace into the program and its
+// arguments (no shell). Unset points are left out.
+func GetNatsHookCommands() map[string][]string {
+	out := make(map[string][]string)
+	for point, key := range map[string]string{
+		"pre-validate": EnvNatsHookPreValidate,
+		"post-sync":    EnvNatsHookPostSync,
+		"pre-reload":   EnvNatsHookPreReload,
+		"post-reload":  EnvNatsHookPostReload,
+		"pre-purge":    EnvNatsHookPrePurge,
+	} {
+		if argv := strings.Fields(os.Getenv(key)); len(argv) > 0 {
+			out[point] = argv
+		}
+	}
+	return out
+}
+
+// GetNatsHookTimeout returns how long a hook may run before it is killed from NATS_HOOK_TIMEOUT, or
+// DefaultNatsHookTimeout (30s) if unset, invalid or zero. A pre-hook that times out vetoes its step.
+func GetNatsHookTimeout() time.Duration {
+	if d := lookupDuration(EnvNatsHookTimeout, DefaultNatsHookTimeout); d > 0 {
+		return d
+	}
+	return DefaultNatsHookTimeout
+}
+
 // GetNatsSysUserCredPath returns the system user credentials file path from NATS_SYS_USER_CRED_PATH.
 // If the value is not an absolute path, it is resolved relative to NATS_CREDS_DIR.
 // Returns empty string if unset.
@@ -147,6 +725,133 @@ func GetNatsClientURL() string {
 	return DefaultNatsClientURL
 }
 
+// GetNatsClientTLS reports whether the wrapper's own client connection uses TLS. It is enabled when
+// NATS_CLIENT_TLS is true, when NATS_CLIENT_URL uses the tls:// scheme, when NATS_CLIENT_TLS_FIRST is true,
+// or when any of NATS_CLIENT_TLS_CA_FILE, NATS_CLIENT_TLS_CERT_FILE or NATS_CLIENT_TLS_KEY_FILE is set.
+func GetNatsClientTLS() bool {
+	if v, ok := lookupBool(EnvNatsClientTLS); ok {
+		return v
+	}
+	if strings.HasPrefix(strings.ToLower(GetNatsClientURL()), "tls://") || GetNatsClientTLSFirst() {
+		return true
+	}
+	return os.Getenv(EnvNatsClientTLSCAFile) != "" || os.Getenv(EnvNatsClientTLSCertFile) != "" || os.Getenv(EnvNatsClientTLSKeyFile) != ""
+}
+
+// GetNatsClientTLSCAFile returns the root CA file used to verify the server on the wrapper's client connection.
+// From NATS_CLIENT_TLS_CA_FILE (relative paths resolved against NATS_SSL_DIR), or ca.crt in NATS_SSL_DIR if that file exists.
+// Returns empty string if neither is available (system roots are used).
+func GetNatsClientTLSCAFile() string {
+	return sslDirFile(EnvNatsClientTLSCAFile, DefaultNatsClientTLSCAFile)
+}
+
+// GetNatsClientTLSCertFile returns the client certificate file for the wrapper's client connection (mTLS).
+// From NATS_CLIENT_TLS_CERT_FILE (relative paths resolved against NATS_SSL_DIR), or tls.crt in NATS_SSL_DIR if that file exists.
+func GetNatsClientTLSCertFile() string {
+	return sslDirFile(EnvNatsClientTLSCertFile, DefaultNatsClientTLSCertFile)
+}
+
+// GetNatsClientTLSKeyFile returns the client private key file for the wrapper's client connection (mTLS).
+// From NATS_CLIENT_TLS_KEY_FILE (relative paths resolved against NATS_SSL_DIR), or tls.key in NATS_SSL_DIR if that file exists.
+func GetNatsClientTLSKeyFile() string {
+	return sslDirFile(EnvNatsClientTLSKeyFile, DefaultNatsClientTLSKeyFile)
+}
+
+// GetNatsClientTLSServerName returns the server name used to verify the server certificate from NATS_CLIENT_TLS_SERVER_NAME.
+// Useful when NATS_CLIENT_URL points at 127.0.0.1 but the certificate is issued for the service name. Empty means the URL host.
+func GetNatsClientTLSServerName() string {
+	return strings.TrimSpace(os.Getenv(EnvNatsClientTLSServerName))
+}
+
+// GetNatsClientTLSFirst reports whether the wrapper's client connection performs the TLS handshake before
+// the server INFO (server must set handshake_first), from NATS_CLIENT_TLS_FIRST.
+func GetNatsClientTLSFirst() bool {
+	v, _ := lookupBool(EnvNatsClientTLSFirst)
+	return v
+}
+
+// GetNatsClientNkeySeedPath returns the nkey seed file for the wrapper's client connection from NATS_CLIENT_NKEY_SEED_PATH.
+// If the value is not an absolute path, it is resolved relative to NATS_CREDS_DIR. Returns empty string if unset.
+func GetNatsClientNkeySeedPath() string {
+	p := os.Getenv(EnvNatsClientNkeySeedPath)
+	if p == "" {
+		return ""
+	}
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(GetNatsCredsDir(), p)
+}
+
+// GetNatsClientToken returns the auth token for the wrapper's client connection from NATS_CLIENT_TOKEN, or empty string if unset.
+func GetNatsClientToken() string {
+	return os.Getenv(EnvNatsClientToken)
+}
+
+// GetNatsDriftCheckInterval returns the interval between resolver drift checks from NATS_DRIFT_CHECK_INTERVAL
+// (Go duration, e.g. "5m"), or DefaultNatsDriftCheckInterval if unset or invalid. Set to 0 to disable.
+func GetNatsDriftCheckInterval() time.Duration {
+	return lookupDuration(EnvNatsDriftCheckInterval, DefaultNatsDriftCheckInterval)
+}
+
+// GetNatsDriftRepush reports whether accounts found drifted (running a different JWT on the server) are pushed
+// again via $SYS.REQ.CLAIMS.UPDATE, from NATS_DRIFT_REPUSH. Default false (report only).
+func GetNatsDriftRepush() bool {
+	v, _ := lookupBool(EnvNatsDriftRepush)
+	return v
+}
+
+// sslDirFile returns the path from env key (relative paths resolved against NATS_SSL_DIR). If the env var is unset,
+// returns defaultName in NATS_SSL_DIR when that file exists, otherwise empty string.
+func sslDirFile(key, defaultName string) string {
+	if p := os.Getenv(key); p != "" {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(GetNatsSSLDir(), p)
+	}
+	p := filepath.Join(GetNatsSSLDir(), defaultName)
+	if info, err := os.Stat(p); err == nil && !info.IsDir() {
+		return p
+	}
+	return ""
+}
+
+// lookupBool parses the env var key as a bool. ok is false if unset or invalid.
+func lookupBool(key string) (v bool, ok bool) {
+	s := strings.TrimSpace(os.Getenv(key))
+	if s == "" {
+		return false, false
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, false
+	}
+	return b, true
+}
+
+// lookupPositiveInt parses the env var key as an int > 0. Returns def if unset, invalid or not positive.
+func lookupPositiveInt(key string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+// lookupDuration parses the env var key as a Go duration. Returns def if unset, invalid or negative.
+func lookupDuration(key string, def time.Duration) time.Duration {
+	s := strings.TrimSpace(os.Getenv(key))
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
 // GetJetStreamStoreDir returns the JetStream store directory. If NATS_JETSTREAM_STORE_DIR is set, uses it
 // (resolving relative paths against the server config file's directory). Otherwise parses jetstream.store_dir
 // from the server config file. Returns empty string if unset or parse fails.
@@ -0,0 +1,50 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package crashloop
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record adds a crash at now to the history file at path (one unix timestamp per line), drops entries older than
+// window, and returns the number of crashes within window including this one. The wrapper exits after a crash, so
+// the history must live on a volume that survives container restarts for crash loops to be detected.
+func Record(path string, now time.Time, window time.Duration) (int, error) {
+	var kept []int64
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 1, err
+	}
+	cutoff := now.Add(-window).Unix()
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		ts, err := strconv.ParseInt(strings.TrimSpace(sc.Text()), 10, 64)
+		if err != nil || ts < cutoff {
+			continue
+		}
+		kept = append(kept, ts)
+	}
+	kept = append(kept, now.Unix())
+	var b strings.Builder
+	for _, ts := range kept {
+		b.WriteString(strconv.FormatInt(ts, 10))
+		b.WriteByte('\n')
+	}
+	return len(kept), os.WriteFile(path, []byte(b.String()), 0644)
+}
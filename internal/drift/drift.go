@@ -0,0 +1,209 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/datasance/nats-server/internal/jspurge"
+	"github.com/datasance/nats-server/internal/natsclient"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	accountzSubjectT = "$SYS.REQ.SERVER.%s.ACCOUNTZ"
+	// globalAccount is the account nats-server always registers; it has no JWT.
+	globalAccount  = "$G"
+	defaultTimeout = 10 * time.Second
+)
+
+// Report is the result of comparing the account JWTs in the resolver directory with the accounts the server runs.
+type Report struct {
+	// Checked is the number of accounts found in the JWT dir.
+	Checked int
+	// Mismatched are accounts the server runs with a JWT that differs from the file on disk and is not newer.
+	Mismatched []string
+	// Unloaded is the number of accounts on disk the server has not loaded yet. They are read from the resolver
+	// dir on first use, so they cannot drift.
+	Unloaded int
+	// Extra are accounts the server runs that are not on disk.
+	Extra []string
+	// Failed maps accounts that could not be read or looked up to the error.
+	Failed map[string]string
+}
+
+// Drifted reports whether any account is mismatched or extra.
+func (r Report) Drifted() bool {
+	return len(r.Mismatched) > 0 || len(r.Extra) > 0
+}
+
+// Repush returns the accounts that a claims push can fix (mismatched). Extra accounts can only be removed with an
+// operator-signed delete, so they are reported but never repushed.
+func (r Report) Repush() []string {
+	return append([]string(nil), r.Mismatched...)
+}
+
+// accountzResponse is the server reply to $SYS.REQ.SERVER.<id>.ACCOUNTZ.
+type accountzResponse struct {
+	Data  *accountz `json:"data,omitempty"`
+	Error *apiError `json:"error,omitempty"`
+}
+
+type accountz struct {
+	Accounts []string     `json:"accounts,omitempty"`
+	Account  *accountInfo `json:"account_detail,omitempty"`
+}
+
+type accountInfo struct {
+	Jwt string `json:"jwt,omitempty"`
+}
+
+type apiError struct {
+	Code        int    `json:"code"`
+	Description string `json:"description,omitempty"`
+}
+
+// Snapshot reads the account JWTs in jwtDir, keyed by account. Files that cannot be read map to nil.
+func Snapshot(jwtDir string) (map[string][]byte, error) {
+	accounts, err := jspurge.AccountsFromJWTDir(jwtDir)
+	if err != nil {
+		return nil, fmt.Errorf("list JWT dir %s: %w", jwtDir, err)
+	}
+	out := make(map[string][]byte, len(accounts))
+	for _, account := range accounts {
+		data, _ := os.ReadFile(filepath.Join(jwtDir, account+".jwt"))
+		out[account] = data
+	}
+	return out, nil
+}
+
+// Check connects to clientURL with credsPath (system account) and compares local, a Snapshot of the JWT dir, with
+// the accounts the connected server runs, via $SYS.REQ.SERVER.<id>.ACCOUNTZ (the /accountz data). An account is
+// mismatched when the server runs a different JWT that was not issued after the file on disk; a newer JWT on the
+// server means a push landed after the snapshot. A failed lookup is recorded in Report.Failed and the check goes on.
+// timeout applies to each request.
+func Check(ctx context.Context, local map[string][]byte, clientURL, credsPath string, timeout time.Duration) (Report, error) {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	nc, err := natsclient.Connect(clientURL, credsPath)
+	if err != nil {
+		return Report{}, fmt.Errorf("connect to %s: %w", clientURL, err)
+	}
+	defer nc.Close()
+	subject := fmt.Sprintf(accountzSubjectT, nc.ConnectedServerId())
+
+	list, err := accountzRequest(ctx, nc, subject, "", timeout)
+	if err != nil {
+		return Report{}, fmt.Errorf("list server accounts: %w", err)
+	}
+	loaded := make(map[string]struct{}, len(list.Accounts))
+	for _, account := range list.Accounts {
+		loaded[account] = struct{}{}
+	}
+
+	report := Report{Checked: len(local), Failed: map[string]string{}}
+	accounts := make([]string, 0, len(local))
+	for account := range local {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	for _, account := range accounts {
+		if _, ok := loaded[account]; !ok {
+			report.Unloaded++
+			continue
+		}
+		data := strings.TrimSpace(string(local[account]))
+		if data == "" {
+			report.Failed[account] = "JWT file unreadable or empty"
+			continue
+		}
+		info, err := accountzRequest(ctx, nc, subject, account, timeout)
+		if err != nil {
+			report.Failed[account] = err.Error()
+			continue
+		}
+		if info.Account == nil {
+			report.Failed[account] = "no account detail in reply"
+			continue
+		}
+		if info.Account.Jwt == data {
+			continue
+		}
+		if newer, err := servedNewer(info.Account.Jwt, data); err != nil {
+			report.Failed[account] = err.Error()
+			continue
+		} else if newer {
+			continue
+		}
+		report.Mismatched = append(report.Mismatched, account)
+	}
+
+	for _, account := range list.Accounts {
+		if _, ok := local[account]; !ok && account != globalAccount {
+			report.Extra = append(report.Extra, account)
+		}
+	}
+	sort.Strings(report.Extra)
+	return report, nil
+}
+
+// accountzRequest sends an ACCOUNTZ request for account (empty lists the loaded accounts) and decodes the reply.
+func accountzRequest(ctx context.Context, nc *nats.Conn, subject, account string, timeout time.Duration) (*accountz, error) {
+	body, err := json.Marshal(map[string]string{"account": account})
+	if err != nil {
+		return nil, err
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	msg, err := nc.RequestWithContext(reqCtx, subject, body)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	var resp accountzResponse
+	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+		return nil, fmt.Errorf("decode reply: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("server error: %s", resp.Error.Description)
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("empty reply")
+	}
+	return resp.Data, nil
+}
+
+// servedNewer reports whether the served JWT was issued after the local one. A server running an account without
+// a JWT is never newer.
+func servedNewer(served, local string) (bool, error) {
+	if served == "" {
+		return false, nil
+	}
+	s, err := jwt.DecodeAccountClaims(served)
+	if err != nil {
+		return false, fmt.Errorf("decode served JWT: %w", err)
+	}
+	l, err := jwt.DecodeAccountClaims(local)
+	if err != nil {
+		return false, fmt.Errorf("decode JWT on disk: %w", err)
+	}
+	return s.IssuedAt > l.IssuedAt, nil
+}
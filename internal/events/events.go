@@ -0,0 +1,132 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/datasance/nats-server/internal/metrics"
+)
+
+// Event types.
+const (
+	// TypeStart: nats-server was started (at startup and after every restart).
+	TypeStart = "start"
+	// TypeReload: a reload or restart decision of the coalescer (causes, action, outcome, error).
+	TypeReload = "reload"
+	// TypeRestart: nats-server is being restarted by the wrapper (reason: leaf config change, config change, watchdog, admin).
+	TypeRestart = "restart"
+	// TypeCrash: nats-server exited unexpectedly; the wrapper exits too.
+	TypeCrash = "crash"
+	// TypeJWTSync: result of a mount to JWT dir sync.
+	TypeJWTSync = "jwt_sync"
+	// TypeClaimsRejected: the server rejected an account JWT pushed via $SYS.REQ.CLAIMS.UPDATE.
+	TypeClaimsRejected = "claims_rejected"
+	// TypePurge: a JetStream account purge was initiated, failed or completed.
+	TypePurge = "purge"
+	// TypeCrashLoop: nats-server crashed repeatedly within the crash loop window (crashes, window).
+	TypeCrashLoop = "crash_loop"
+)
+
+// Reasons of TypeRestart events.
+const (
+	ReasonLeafConfigChange = "leaf config change"
+	ReasonConfigChange     = "config change"
+	ReasonWatchdog         = "watchdog"
+	ReasonAdmin            = "admin"
+)
+
+// Outcomes of TypePurge events.
+const (
+	PurgeInitiated = "initiated"
+	PurgeFailed    = "failed"
+	PurgeCompleted = "completed"
+)
+
+// DefaultQueueSize is the queue size of the built-in sinks.
+const DefaultQueueSize = 1024
+
+// Event is a wrapper lifecycle event.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// Server is the server name; set by sinks that know it (e.g. the NATS publisher).
+	Server string         `json:"server,omitempty"`
+	Data   map[string]any `json:"data,omitempty"`
+}
+
+// Bus fans out events to sinks. Each sink has its own bounded queue drained by its own goroutine, so a slow sink
+// never blocks the emitter; events that do not fit are dropped and counted in pot_nats_events_total.
+type Bus struct {
+	mu      sync.RWMutex
+	sinks   []*sink
+	pending atomic.Int64
+}
+
+type sink struct {
+	name string
+	ch   chan Event
+}
+
+// Default is the bus used by Emit.
+var Default = new(Bus)
+
+// Emit sends an event of type typ with data on the Default bus.
+func Emit(typ string, data map[string]any) {
+	Default.Emit(Event{Type: typ, Time: time.Now().UTC(), Data: data})
+}
+
+// Flush waits until the Default bus sinks have handled all queued events or timeout elapses.
+func Flush(timeout time.Duration) {
+	Default.Flush(timeout)
+}
+
+// Subscribe adds a sink called name (used in metrics) with a queue of queueSize events. handle is called from a
+// dedicated goroutine, one event at a time.
+func (b *Bus) Subscribe(name string, queueSize int, handle func(Event)) {
+	s := &sink{name: name, ch: make(chan Event, max(queueSize, 1))}
+	b.mu.Lock()
+	b.sinks = append(b.sinks, s)
+	b.mu.Unlock()
+	go func() {
+		for e := range s.ch {
+			handle(e)
+			b.pending.Add(-1)
+		}
+	}()
+}
+
+// Emit queues e for every sink without blocking.
+func (b *Bus) Emit(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.sinks {
+		b.pending.Add(1)
+		select {
+		case s.ch <- e:
+		default:
+			b.pending.Add(-1)
+			metrics.Events.Inc(s.name, metrics.OutcomeDropped)
+		}
+	}
+}
+
+// Flush waits until all queued events have been handled or timeout elapses, e.g. before the wrapper exits.
+func (b *Bus) Flush(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for b.pending.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
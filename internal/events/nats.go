@@ -0,0 +1,119 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
+	"github.com/datasance/nats-server/internal/natsclient"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var logger = logging.Component("events")
+
+// sinkNATS is the sink name of the NATS publisher in metrics.
+const sinkNATS = "nats"
+
+// NATSOptions configures the NATS event publisher.
+type NATSOptions struct {
+	// URL and CredsPath of the connection; the creds select the account events are published in.
+	URL       string
+	CredsPath string
+	// SubjectPrefix, e.g. "$POT.NATS.EVENT"; events go to <prefix>.<server_name>.<type>.
+	SubjectPrefix string
+	// Stream, if set, is created or updated to retain <prefix>.> for StreamMaxAge. JetStream is not available in
+	// the system account, so this requires credentials of a designated account.
+	Stream       string
+	StreamMaxAge time.Duration
+	// QueueSize bounds the events waiting to be published.
+	QueueSize int
+}
+
+// SubscribeNATS subscribes a publisher sink to b that publishes each event as JSON to
+// <SubjectPrefix>.<server_name>.<type>. The connection is retried and re-established forever in the background;
+// while it is down events wait in the queue (and are dropped when it is full).
+func SubscribeNATS(b *Bus, opts NATSOptions) error {
+	copts, err := natsclient.Options(opts.CredsPath)
+	if err != nil {
+		return err
+	}
+	connected := make(chan struct{})
+	var once sync.Once
+	markConnected := func() { once.Do(func() { close(connected) }) }
+	copts = append(copts,
+		nats.Name("pot-nats-events"),
+		nats.MaxReconnects(-1),
+		nats.RetryOnFailedConnect(true),
+		nats.ConnectHandler(func(*nats.Conn) { markConnected() }),
+	)
+	nc, err := nats.Connect(opts.URL, copts...)
+	if err != nil {
+		return err
+	}
+	if nc.IsConnected() {
+		markConnected()
+	}
+	natsclient.Track("events", nc)
+	var serverName string
+	b.Subscribe(sinkNATS, opts.QueueSize, func(e Event) {
+		if serverName == "" {
+			// The server name comes with the first INFO; wait for the first connect.
+			<-connected
+			serverName = nc.ConnectedServerName()
+			if opts.Stream != "" {
+				ensureStream(nc, opts)
+			}
+		}
+		e.Server = serverName
+		data, err := json.Marshal(e)
+		if err != nil {
+			metrics.Events.Inc(sinkNATS, metrics.OutcomeFailure)
+			return
+		}
+		subject := opts.SubjectPrefix + "." + natsclient.SubjectToken(serverName) + "." + e.Type
+		if err := nc.Publish(subject, data); err != nil {
+			logger.Warn("Event publish failed", "subject", subject, logging.Err(err))
+			metrics.Events.Inc(sinkNATS, metrics.OutcomeFailure)
+			return
+		}
+		metrics.Events.Inc(sinkNATS, metrics.OutcomeSuccess)
+	})
+	logger.Info("Publishing lifecycle events to NATS", "subjects", opts.SubjectPrefix+".<server_name>.<type>", "stream", opts.Stream)
+	return nil
+}
+
+// ensureStream creates or updates the stream retaining events. Failures are logged; events are still published.
+func ensureStream(nc *nats.Conn, opts NATSOptions) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		logger.Error("Event stream: JetStream unavailable", "stream", opts.Stream, logging.Err(err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        opts.Stream,
+		Description: "pot-nats wrapper lifecycle events",
+		Subjects:    []string{opts.SubjectPrefix + ".>"},
+		MaxAge:      opts.StreamMaxAge,
+	})
+	if err != nil {
+		logger.Error("Event stream: create or update failed", "stream", opts.Stream, logging.Err(err))
+	}
+}
@@ -14,23 +14,57 @@ package exec
 
 import (
 	"bufio"
-	"fmt"
-	"log"
+	"errors"
+	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/datasance/nats-server/internal/logging"
+)
+
+var logger = logging.Component("exec")
+
+// maxLineLength caps a single forwarded output line; longer lines are cut (with truncatedSuffix) instead of
+// stalling or dropping the child's output.
+const (
+	maxLineLength   = 1 << 20
+	truncatedSuffix = " ...[truncated]"
 )
 
+// Output streams passed to the onLine callback of Start.
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// Cmd is a command started by Start. Its Wait waits for the output to be forwarded before it waits for the
+// process, as required when reading from the pipes of an exec.Cmd.
+type Cmd struct {
+	*exec.Cmd
+	readers sync.WaitGroup
+}
+
+// Wait waits until stdout and stderr are closed and all their lines were passed to onLine, then waits for the
+// command to exit.
+func (c *Cmd) Wait() error {
+	c.readers.Wait()
+	return c.Cmd.Wait()
+}
+
 // Start starts the command with the given args and optional extra environment variables.
 // The process environment is always preserved: cmd.Env = append(os.Environ(), extraEnv...),
 // so that placeholders like $SERVER_NAME in config files are resolved by the child.
 // workDir is the working directory for the process (e.g. config file's directory); empty means current dir.
-// Stdout and stderr are forwarded to the parent's output. The returned *exec.Cmd can be used
+// Stdout and stderr are read line by line and each line is passed to onLine with its stream (Stdout or Stderr);
+// onLine is called from one goroutine per stream. The returned *Cmd can be used
 // to send signals (e.g. SIGHUP for config reload) via cmd.Process.Signal(syscall.SIGHUP).
 // The caller must run cmd.Wait() in a goroutine and send the result to an exit channel.
-func Start(name string, args []string, extraEnv []string, workDir string) (*exec.Cmd, error) {
-	log.Printf("Starting command: %s with args: %v", name, args)
+func Start(name string, args []string, extraEnv []string, workDir string, onLine func(stream, line string)) (*Cmd, error) {
+	logger.Info("Starting command", "command", name, "args", args)
 
-	cmd := exec.Command(name, args...)
+	cmd := &Cmd{Cmd: exec.Command(name, args...)}
 	cmd.Env = append(os.Environ(), extraEnv...)
 	if workDir != "" {
 		cmd.Dir = workDir
@@ -40,26 +74,53 @@ func Start(name string, args []string, extraEnv []string, workDir string) (*exec
 	if err != nil {
 		return nil, err
 	}
-	go func() {
-		scanner := bufio.NewScanner(outReader)
-		for scanner.Scan() {
-			fmt.Println(scanner.Text())
-		}
-	}()
-
 	errReader, err := cmd.StderrPipe()
 	if err != nil {
 		return nil, err
 	}
-	go func() {
-		scanner := bufio.NewScanner(errReader)
-		for scanner.Scan() {
-			fmt.Println(scanner.Text())
-		}
-	}()
-
 	if err := cmd.Start(); err != nil {
 		return nil, err
 	}
+	// The readers start only once the process is: a failed start closes the pipes, and nothing waits for them.
+	cmd.readers.Add(2)
+	go func() {
+		defer cmd.readers.Done()
+		readLines(outReader, func(line string) { onLine(Stdout, line) })
+	}()
+	go func() {
+		defer cmd.readers.Done()
+		readLines(errReader, func(line string) { onLine(Stderr, line) })
+	}()
 	return cmd, nil
 }
+
+// readLines calls fn for each line of r until EOF or a read error. Unlike bufio.Scanner it has no 64KB token
+// limit: lines longer than maxLineLength are truncated and the rest of the line is discarded.
+func readLines(r io.Reader, fn func(line string)) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var buf []byte
+	truncated := false
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if room := maxLineLength - len(buf); len(chunk) > room {
+			buf = append(buf, chunk[:max(room, 0)]...)
+			truncated = true
+		} else {
+			buf = append(buf, chunk...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if len(buf) > 0 {
+			line := strings.TrimRight(string(buf), "\r\n")
+			if truncated {
+				line += truncatedSuffix
+			}
+			fn(line)
+		}
+		buf, truncated = buf[:0], false
+		if err != nil {
+			return
+		}
+	}
+}
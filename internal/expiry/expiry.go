@@ -0,0 +1,247 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+// Package expiry finds the certificates, JWTs and creds the server depends on and warns before they expire.
+package expiry
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
+	"github.com/datasance/nats-server/internal/status"
+	"github.com/datasance/nats-server/internal/validate"
+	"github.com/nats-io/jwt/v2"
+)
+
+var logger = logging.Component("expiry")
+
+// Kinds of Item.
+const (
+	KindCert  = "cert"
+	KindJWT   = "jwt"
+	KindCreds = "creds"
+)
+
+// Item is a certificate, JWT or creds user JWT with an expiry.
+type Item struct {
+	Kind    string
+	Path    string
+	Subject string
+	Expires time.Time
+}
+
+func (it Item) String() string {
+	return fmt.Sprintf("%s %s (%s) expires at %s", it.Kind, it.Path, it.Subject, it.Expires.UTC().Format(time.RFC3339))
+}
+
+// Sources are where Scan looks for items. Empty fields are skipped.
+type Sources struct {
+	// SSLDir is walked for certificates (*.crt, *.pem).
+	SSLDir string
+	// CredsDir is walked for creds files (*.creds).
+	CredsDir string
+	// JWTDir is read for account JWTs (*.jwt).
+	JWTDir string
+	// Config is the server config; the certificates and creds its tls blocks and remotes reference are included.
+	Config string
+	// Creds are further creds files, e.g. the system user creds.
+	Creds []string
+}
+
+// Scan returns the items of src that expire, sorted by expiry. Unreadable files are skipped: validation and
+// readiness report those.
+func Scan(src Sources) []Item {
+	seen := make(map[string]bool)
+	var out []Item
+	add := func(items ...Item) {
+		for _, it := range items {
+			key := it.Kind + "\x00" + it.Path + "\x00" + it.Subject
+			if it.Expires.IsZero() || seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, it)
+		}
+	}
+	var certs, creds []string
+	walk(src.SSLDir, func(path string) {
+		if strings.HasSuffix(path, ".crt") || strings.HasSuffix(path, ".pem") {
+			certs = append(certs, path)
+		}
+	})
+	walk(src.CredsDir, func(path string) {
+		if strings.HasSuffix(path, ".creds") {
+			creds = append(creds, path)
+		}
+	})
+	creds = append(creds, src.Creds...)
+	if src.Config != "" {
+		if refs, err := validate.ParseConfigRefs(src.Config); err == nil {
+			for _, set := range refs.TLS {
+				certs = append(certs, set.Cert, set.CA)
+			}
+			creds = append(creds, refs.Creds...)
+		}
+	}
+	for _, p := range certs {
+		add(certItems(p)...)
+	}
+	for _, p := range creds {
+		add(credsItem(p))
+	}
+	if src.JWTDir != "" {
+		matches, _ := filepath.Glob(filepath.Join(src.JWTDir, "*.jwt"))
+		for _, p := range matches {
+			add(jwtItem(p))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Expires.Before(out[j].Expires) })
+	return out
+}
+
+// walk calls fn for each regular file under dir, skipping Kubernetes volume internals (names starting with "..").
+func walk(dir string, fn func(path string)) {
+	if dir == "" {
+		return
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), "..") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			fn(path)
+		}
+		return nil
+	})
+}
+
+func certItems(path string) []Item {
+	if path == "" {
+		return nil
+	}
+	chain, err := validate.ParseCertificates(path)
+	if err != nil {
+		return nil
+	}
+	out := make([]Item, len(chain))
+	for i, c := range chain {
+		out[i] = Item{Kind: KindCert, Path: path, Subject: c.Subject.String(), Expires: c.NotAfter}
+	}
+	return out
+}
+
+func credsItem(path string) Item {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Item{}
+	}
+	token, err := jwt.ParseDecoratedJWT(data)
+	if err != nil {
+		return Item{}
+	}
+	return claimsItem(KindCreds, path, token)
+}
+
+func jwtItem(path string) Item {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Item{}
+	}
+	return claimsItem(KindJWT, path, strings.TrimSpace(string(data)))
+}
+
+func claimsItem(kind, path, token string) Item {
+	c, err := jwt.DecodeGeneric(token)
+	if err != nil || c.Expires == 0 {
+		return Item{}
+	}
+	subject := c.Subject
+	if c.Name != "" {
+		subject = c.Name + " " + c.Subject
+	}
+	return Item{Kind: kind, Path: path, Subject: subject, Expires: time.Unix(c.Expires, 0)}
+}
+
+// Monitor logs items crossing the warning thresholds and records them in metrics and the tracker.
+type Monitor struct {
+	// Thresholds are the warning thresholds, e.g. 30, 7 and 1 days before expiry.
+	Thresholds []time.Duration
+
+	// warned is the smallest threshold (or 0 once expired) logged for each item.
+	warned map[Item]time.Duration
+}
+
+// Record publishes items scanned at now: pot_nats_expiry_days for each, a warning the first time an item crosses
+// each threshold (an error once it has expired), and the expired and expiring items to tracker.
+func (m *Monitor) Record(items []Item, now time.Time, tracker *status.Tracker) {
+	if m.warned == nil {
+		m.warned = make(map[Item]time.Duration)
+	}
+	thresholds := append([]time.Duration(nil), m.Thresholds...)
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i] < thresholds[j] })
+	var largest time.Duration
+	if len(thresholds) > 0 {
+		largest = thresholds[len(thresholds)-1]
+	}
+
+	summary := status.Expiry{Time: now.UTC()}
+	current := make(map[Item]bool, len(items))
+	metrics.ExpiryDays.Reset()
+	for _, it := range items {
+		current[it] = true
+		left := it.Expires.Sub(now)
+		metrics.ExpiryDays.Set(left.Hours()/24, it.Kind, it.Path, it.Subject)
+		prev, logged := m.warned[it]
+		switch {
+		case left <= 0:
+			summary.Expired = append(summary.Expired, it.String())
+			if !logged || prev > 0 {
+				logger.Error("Expired", "kind", it.Kind, "path", it.Path, "subject", it.Subject, "expires", it.Expires)
+				m.warned[it] = 0
+			}
+		case left <= largest:
+			summary.Expiring = append(summary.Expiring, it.String())
+			for _, t := range thresholds {
+				if left > t {
+					continue
+				}
+				if !logged || t < prev {
+					logger.Warn("Expiring soon", "kind", it.Kind, "path", it.Path, "subject", it.Subject, "expires", it.Expires, "days_left", int(left.Hours()/24), "threshold_days", int(t.Hours()/24))
+					m.warned[it] = t
+				}
+				break
+			}
+		}
+	}
+	for it := range m.warned {
+		if !current[it] {
+			delete(m.warned, it)
+		}
+	}
+	tracker.ExpiryDone(summary)
+}
@@ -0,0 +1,394 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/datasance/nats-server/internal/config"
+	"github.com/datasance/nats-server/internal/metrics"
+)
+
+// scrapeTimeout bounds a whole Collect, not each endpoint, so a hung nats-server cannot stall /metrics for long.
+const scrapeTimeout = 5 * time.Second
+
+// Collector scrapes the child's nats-server monitoring endpoints at scrape time and writes them as Prometheus
+// metrics (prefix nats_server_). Account, stream, leafnode and route series are capped so a busy server
+// cannot blow up the cardinality of the wrapper's /metrics; the number of series left out is exported as
+// nats_server_exporter_dropped_series{kind}.
+type Collector struct {
+	baseURL     string
+	endpoints   map[string]bool
+	maxAccounts int
+	maxStreams  int
+	maxConns    int
+	client      *http.Client
+}
+
+// New returns a Collector for the monitoring port of the local nats-server, configured from the NATS_EXPORTER_* env vars.
+func New(monitorPort int) *Collector {
+	endpoints := make(map[string]bool)
+	for _, e := range config.GetNatsExporterEndpoints() {
+		endpoints[e] = true
+	}
+	return &Collector{
+		baseURL:     "http://127.0.0.1:" + strconv.Itoa(monitorPort),
+		endpoints:   endpoints,
+		maxAccounts: config.GetNatsExporterMaxAccounts(),
+		maxStreams:  config.GetNatsExporterMaxStreams(),
+		maxConns:    config.GetNatsExporterMaxConnections(),
+		client:      &http.Client{},
+	}
+}
+
+// Collect implements metrics.Collector. All endpoints share one scrapeTimeout deadline; those not answered by then
+// are reported down.
+func (c *Collector) Collect(w *metrics.Writer) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+	var up, dropped []row
+	scrape := func(endpoint string, fn func(ctx context.Context, w *metrics.Writer) error) {
+		if !c.endpoints[endpoint] {
+			return
+		}
+		v := 1.0
+		if err := fn(ctx, w); err != nil {
+			v = 0
+		}
+		up = append(up, row{[]string{endpoint}, v})
+	}
+	drop := func(kind string, n int) {
+		if n > 0 {
+			dropped = append(dropped, row{[]string{kind}, float64(n)})
+		}
+	}
+
+	scrape("varz", c.collectVarz)
+	scrape("connz", func(ctx context.Context, w *metrics.Writer) error {
+		nConns, nAcc, err := c.collectConnz(ctx, w)
+		drop("connz_connections", nConns)
+		drop("connz_accounts", nAcc)
+		return err
+	})
+	scrape("jsz", func(ctx context.Context, w *metrics.Writer) error {
+		nAcc, nStreams, err := c.collectJsz(ctx, w)
+		drop("jsz_accounts", nAcc)
+		drop("jsz_streams", nStreams)
+		return err
+	})
+	scrape("leafz", func(ctx context.Context, w *metrics.Writer) error {
+		n, err := c.collectLeafz(ctx, w)
+		drop("leafz_leafnodes", n)
+		return err
+	})
+	scrape("routez", func(ctx context.Context, w *metrics.Writer) error {
+		n, err := c.collectRoutez(ctx, w)
+		drop("routez_routes", n)
+		return err
+	})
+	scrape("accountz", c.collectAccountz)
+
+	family(w, "nats_server_exporter_up", "Whether the last scrape of the nats-server monitoring endpoint succeeded.", "gauge", []string{"endpoint"}, up)
+	family(w, "nats_server_exporter_dropped_series", "Series left out of the last scrape because of the cardinality limits.", "gauge", []string{"kind"}, dropped)
+}
+
+func (c *Collector) collectVarz(ctx context.Context, w *metrics.Writer) error {
+	var v varz
+	if err := c.get(ctx, "/varz", &v); err != nil {
+		return err
+	}
+	family(w, "nats_server_info", "nats-server identity; always 1.", "gauge",
+		[]string{"server_id", "server_name", "version"}, []row{{[]string{v.ServerID, v.ServerName, v.Version}, 1}})
+	single(w, "nats_server_uptime_seconds", "Seconds since nats-server started.", "gauge", v.Now.Sub(v.Start).Seconds())
+	single(w, "nats_server_mem_bytes", "Resident memory of nats-server.", "gauge", float64(v.Mem))
+	single(w, "nats_server_cpu_percent", "CPU usage of nats-server.", "gauge", v.CPU)
+	single(w, "nats_server_cores", "CPU cores available to nats-server.", "gauge", float64(v.Cores))
+	single(w, "nats_server_connections", "Current client connections.", "gauge", float64(v.Connections))
+	single(w, "nats_server_connections_total", "Client connections since start.", "counter", float64(v.TotalConnections))
+	single(w, "nats_server_routes", "Current route connections.", "gauge", float64(v.Routes))
+	single(w, "nats_server_remotes", "Current remote (cluster) servers.", "gauge", float64(v.Remotes))
+	single(w, "nats_server_leafnodes", "Current leafnode connections.", "gauge", float64(v.Leafnodes))
+	single(w, "nats_server_subscriptions", "Current subscriptions.", "gauge", float64(v.Subscriptions))
+	single(w, "nats_server_slow_consumers_total", "Slow consumers since start.", "counter", float64(v.SlowConsumers))
+	single(w, "nats_server_in_msgs_total", "Messages received.", "counter", float64(v.InMsgs))
+	single(w, "nats_server_out_msgs_total", "Messages sent.", "counter", float64(v.OutMsgs))
+	single(w, "nats_server_in_bytes_total", "Bytes received.", "counter", float64(v.InBytes))
+	single(w, "nats_server_out_bytes_total", "Bytes sent.", "counter", float64(v.OutBytes))
+	if js := v.JetStream.Stats; js != nil {
+		single(w, "nats_server_jetstream_memory_bytes", "JetStream memory storage in use.", "gauge", float64(js.Memory))
+		single(w, "nats_server_jetstream_storage_bytes", "JetStream file storage in use.", "gauge", float64(js.Storage))
+		single(w, "nats_server_jetstream_accounts", "Accounts with JetStream enabled.", "gauge", float64(js.Accounts))
+		single(w, "nats_server_jetstream_ha_assets", "JetStream replicated assets.", "gauge", float64(js.HAAssets))
+		single(w, "nats_server_jetstream_api_requests_total", "JetStream API requests.", "counter", float64(js.API.Total))
+		single(w, "nats_server_jetstream_api_errors_total", "JetStream API errors.", "counter", float64(js.API.Errors))
+	}
+	return nil
+}
+
+// collectConnz aggregates client connections per account. Only the first maxConns connections are fetched, so on a
+// busier server the per-account values are partial. Returns the number of connections and accounts left out.
+func (c *Collector) collectConnz(ctx context.Context, w *metrics.Writer) (int, int, error) {
+	var v connz
+	if err := c.get(ctx, "/connz?auth=true&limit="+strconv.Itoa(c.maxConns), &v); err != nil {
+		return 0, 0, err
+	}
+	droppedConns := max(v.Total-len(v.Conns), 0)
+	type agg struct {
+		conns, subs, pending               float64
+		inMsgs, outMsgs, inBytes, outBytes float64
+	}
+	byAccount := make(map[string]*agg)
+	for _, ci := range v.Conns {
+		a := byAccount[ci.Account]
+		if a == nil {
+			a = &agg{}
+			byAccount[ci.Account] = a
+		}
+		a.conns++
+		a.subs += float64(ci.Subscriptions)
+		a.pending += float64(ci.PendingBytes)
+		a.inMsgs += float64(ci.InMsgs)
+		a.outMsgs += float64(ci.OutMsgs)
+		a.inBytes += float64(ci.InBytes)
+		a.outBytes += float64(ci.OutBytes)
+	}
+	accounts := make([]string, 0, len(byAccount))
+	for name := range byAccount {
+		accounts = append(accounts, name)
+	}
+	accounts, droppedAccounts := topN(accounts, c.maxAccounts, func(a string) float64 { return byAccount[a].conns })
+
+	labels := []string{"account"}
+	rows := func(fn func(*agg) float64) []row {
+		out := make([]row, 0, len(accounts))
+		for _, name := range accounts {
+			out = append(out, row{[]string{name}, fn(byAccount[name])})
+		}
+		return out
+	}
+	single(w, "nats_server_connz_connections", "Client connections reported by /connz.", "gauge", float64(v.Total))
+	family(w, "nats_server_account_connections", "Client connections per account. Partial when /connz has more connections than NATS_EXPORTER_MAX_CONNECTIONS (see nats_server_exporter_dropped_series{kind=\"connz_connections\"}).", "gauge", labels, rows(func(a *agg) float64 { return a.conns }))
+	family(w, "nats_server_account_subscriptions", "Subscriptions of client connections per account. Partial when /connz has more connections than NATS_EXPORTER_MAX_CONNECTIONS (see nats_server_exporter_dropped_series{kind=\"connz_connections\"}).", "gauge", labels, rows(func(a *agg) float64 { return a.subs }))
+	family(w, "nats_server_account_pending_bytes", "Pending outbound bytes of client connections per account. Partial when /connz has more connections than NATS_EXPORTER_MAX_CONNECTIONS (see nats_server_exporter_dropped_series{kind=\"connz_connections\"}).", "gauge", labels, rows(func(a *agg) float64 { return a.pending }))
+	family(w, "nats_server_account_in_msgs", "Messages received from current client connections per account. Partial when /connz has more connections than NATS_EXPORTER_MAX_CONNECTIONS (see nats_server_exporter_dropped_series{kind=\"connz_connections\"}).", "gauge", labels, rows(func(a *agg) float64 { return a.inMsgs }))
+	family(w, "nats_server_account_out_msgs", "Messages sent to current client connections per account. Partial when /connz has more connections than NATS_EXPORTER_MAX_CONNECTIONS (see nats_server_exporter_dropped_series{kind=\"connz_connections\"}).", "gauge", labels, rows(func(a *agg) float64 { return a.outMsgs }))
+	family(w, "nats_server_account_in_bytes", "Bytes received from current client connections per account. Partial when /connz has more connections than NATS_EXPORTER_MAX_CONNECTIONS (see nats_server_exporter_dropped_series{kind=\"connz_connections\"}).", "gauge", labels, rows(func(a *agg) float64 { return a.inBytes }))
+	family(w, "nats_server_account_out_bytes", "Bytes sent to current client connections per account. Partial when /connz has more connections than NATS_EXPORTER_MAX_CONNECTIONS (see nats_server_exporter_dropped_series{kind=\"connz_connections\"}).", "gauge", labels, rows(func(a *agg) float64 { return a.outBytes }))
+	return droppedConns, droppedAccounts, nil
+}
+
+// collectJsz exports JetStream usage per account and per stream. Returns the number of accounts and streams left out.
+func (c *Collector) collectJsz(ctx context.Context, w *metrics.Writer) (int, int, error) {
+	var v jsz
+	if err := c.get(ctx, "/jsz?accounts=true&streams=true", &v); err != nil {
+		return 0, 0, err
+	}
+	single(w, "nats_server_jetstream_streams", "JetStream streams.", "gauge", float64(v.Streams))
+	single(w, "nats_server_jetstream_consumers", "JetStream consumers.", "gauge", float64(v.Consumers))
+	single(w, "nats_server_jetstream_messages", "Messages stored in JetStream.", "gauge", float64(v.Messages))
+	single(w, "nats_server_jetstream_bytes", "Bytes stored in JetStream.", "gauge", float64(v.Bytes))
+
+	byName := make(map[string]accountDetail, len(v.Accounts))
+	names := make([]string, 0, len(v.Accounts))
+	type stream struct {
+		account string
+		detail  streamDetail
+	}
+	var streams []stream
+	for _, acc := range v.Accounts {
+		byName[acc.Name] = acc
+		names = append(names, acc.Name)
+		for _, sd := range acc.Streams {
+			streams = append(streams, stream{acc.Name, sd})
+		}
+	}
+	names, droppedAccounts := topN(names, c.maxAccounts, func(a string) float64 { return float64(byName[a].Memory + byName[a].Storage) })
+	var memRows, storeRows, countRows []row
+	for _, name := range names {
+		acc := byName[name]
+		memRows = append(memRows, row{[]string{name}, float64(acc.Memory)})
+		storeRows = append(storeRows, row{[]string{name}, float64(acc.Storage)})
+		countRows = append(countRows, row{[]string{name}, float64(len(acc.Streams))})
+	}
+	accLabels := []string{"account"}
+	family(w, "nats_server_jetstream_account_memory_bytes", "JetStream memory storage in use per account.", "gauge", accLabels, memRows)
+	family(w, "nats_server_jetstream_account_storage_bytes", "JetStream file storage in use per account.", "gauge", accLabels, storeRows)
+	family(w, "nats_server_jetstream_account_streams", "JetStream streams per account.", "gauge", accLabels, countRows)
+
+	sort.SliceStable(streams, func(i, j int) bool { return streams[i].detail.State.Bytes > streams[j].detail.State.Bytes })
+	droppedStreams := 0
+	if len(streams) > c.maxStreams {
+		droppedStreams = len(streams) - c.maxStreams
+		streams = streams[:c.maxStreams]
+	}
+	var msgRows, byteRows, consRows []row
+	for _, s := range streams {
+		lv := []string{s.account, s.detail.Name}
+		msgRows = append(msgRows, row{lv, float64(s.detail.State.Messages)})
+		byteRows = append(byteRows, row{lv, float64(s.detail.State.Bytes)})
+		consRows = append(consRows, row{lv, float64(s.detail.State.Consumers)})
+	}
+	streamLabels := []string{"account", "stream"}
+	family(w, "nats_server_jetstream_stream_messages", "Messages stored per stream.", "gauge", streamLabels, msgRows)
+	family(w, "nats_server_jetstream_stream_bytes", "Bytes stored per stream.", "gauge", streamLabels, byteRows)
+	family(w, "nats_server_jetstream_stream_consumers", "Consumers per stream.", "gauge", streamLabels, consRows)
+	return droppedAccounts, droppedStreams, nil
+}
+
+// collectLeafz exports leafnode connections. Returns the number of leafnodes left out.
+func (c *Collector) collectLeafz(ctx context.Context, w *metrics.Writer) (int, error) {
+	var v leafz
+	if err := c.get(ctx, "/leafz", &v); err != nil {
+		return 0, err
+	}
+	leafs := v.Leafs
+	dropped := 0
+	if len(leafs) > c.maxConns {
+		dropped = len(leafs) - c.maxConns
+		leafs = leafs[:c.maxConns]
+	}
+	var subs, inMsgs, outMsgs, inBytes, outBytes []row
+	for _, l := range leafs {
+		// The name is the remote server name and is not unique (e.g. several leafnodes of one remote); the cid is.
+		lv := []string{strconv.FormatUint(l.CID, 10), l.Account, l.Name}
+		subs = append(subs, row{lv, float64(l.Subscriptions)})
+		inMsgs = append(inMsgs, row{lv, float64(l.InMsgs)})
+		outMsgs = append(outMsgs, row{lv, float64(l.OutMsgs)})
+		inBytes = append(inBytes, row{lv, float64(l.InBytes)})
+		outBytes = append(outBytes, row{lv, float64(l.OutBytes)})
+	}
+	labels := []string{"cid", "account", "name"}
+	single(w, "nats_server_leafz_leafnodes", "Leafnode connections reported by /leafz.", "gauge", float64(v.Leafnodes))
+	family(w, "nats_server_leafnode_subscriptions", "Subscriptions per leafnode connection.", "gauge", labels, subs)
+	family(w, "nats_server_leafnode_in_msgs_total", "Messages received per leafnode connection.", "counter", labels, inMsgs)
+	family(w, "nats_server_leafnode_out_msgs_total", "Messages sent per leafnode connection.", "counter", labels, outMsgs)
+	family(w, "nats_server_leafnode_in_bytes_total", "Bytes received per leafnode connection.", "counter", labels, inBytes)
+	family(w, "nats_server_leafnode_out_bytes_total", "Bytes sent per leafnode connection.", "counter", labels, outBytes)
+	return dropped, nil
+}
+
+// collectRoutez exports route connections per remote server; the pooled routes to one remote are summed. Returns
+// the number of remotes left out.
+func (c *Collector) collectRoutez(ctx context.Context, w *metrics.Writer) (int, error) {
+	var v routez
+	if err := c.get(ctx, "/routez", &v); err != nil {
+		return 0, err
+	}
+	type agg struct {
+		name                               string
+		pending                            float64
+		inMsgs, outMsgs, inBytes, outBytes float64
+	}
+	byRemote := make(map[string]*agg)
+	for _, r := range v.Routes {
+		a := byRemote[r.RemoteID]
+		if a == nil {
+			a = &agg{name: r.RemoteName}
+			byRemote[r.RemoteID] = a
+		}
+		a.pending += float64(r.Pending)
+		a.inMsgs += float64(r.InMsgs)
+		a.outMsgs += float64(r.OutMsgs)
+		a.inBytes += float64(r.InBytes)
+		a.outBytes += float64(r.OutBytes)
+	}
+	remotes := make([]string, 0, len(byRemote))
+	for id := range byRemote {
+		remotes = append(remotes, id)
+	}
+	remotes, dropped := topN(remotes, c.maxConns, func(id string) float64 { return byRemote[id].inBytes + byRemote[id].outBytes })
+
+	labels := []string{"remote_id", "remote_name"}
+	rows := func(fn func(*agg) float64) []row {
+		out := make([]row, 0, len(remotes))
+		for _, id := range remotes {
+			out = append(out, row{[]string{id, byRemote[id].name}, fn(byRemote[id])})
+		}
+		return out
+	}
+	single(w, "nats_server_routez_routes", "Route connections reported by /routez.", "gauge", float64(v.NumRoutes))
+	family(w, "nats_server_route_pending_bytes", "Pending outbound bytes per remote server.", "gauge", labels, rows(func(a *agg) float64 { return a.pending }))
+	family(w, "nats_server_route_in_msgs_total", "Messages received per remote server.", "counter", labels, rows(func(a *agg) float64 { return a.inMsgs }))
+	family(w, "nats_server_route_out_msgs_total", "Messages sent per remote server.", "counter", labels, rows(func(a *agg) float64 { return a.outMsgs }))
+	family(w, "nats_server_route_in_bytes_total", "Bytes received per remote server.", "counter", labels, rows(func(a *agg) float64 { return a.inBytes }))
+	family(w, "nats_server_route_out_bytes_total", "Bytes sent per remote server.", "counter", labels, rows(func(a *agg) float64 { return a.outBytes }))
+	return dropped, nil
+}
+
+func (c *Collector) collectAccountz(ctx context.Context, w *metrics.Writer) error {
+	var v accountz
+	if err := c.get(ctx, "/accountz", &v); err != nil {
+		return err
+	}
+	single(w, "nats_server_accounts", "Accounts loaded by nats-server.", "gauge", float64(len(v.Accounts)))
+	return nil
+}
+
+// get fetches path from the monitoring endpoint and decodes the JSON response into out.
+func (c *Collector) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// row is one sample of a labelled family.
+type row struct {
+	values []string
+	v      float64
+}
+
+func single(w *metrics.Writer, name, help, typ string, v float64) {
+	w.Family(name, help, typ)
+	w.Sample(name, nil, nil, v)
+}
+
+func family(w *metrics.Writer, name, help, typ string, labels []string, rows []row) {
+	if len(rows) == 0 {
+		return
+	}
+	w.Family(name, help, typ)
+	for _, r := range rows {
+		w.Sample(name, labels, r.values, r.v)
+	}
+}
+
+// topN returns the n names with the largest weight (ties by name) and how many were left out.
+func topN(names []string, n int, weight func(string) float64) ([]string, int) {
+	sort.Slice(names, func(i, j int) bool {
+		wi, wj := weight(names[i]), weight(names[j])
+		if wi != wj {
+			return wi > wj
+		}
+		return names[i] < names[j]
+	})
+	if len(names) <= n {
+		return names, 0
+	}
+	return names[:n], len(names) - n
+}
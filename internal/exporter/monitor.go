@@ -0,0 +1,130 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package exporter
+
+import "time"
+
+// Subsets of the nats-server monitoring responses; only the fields exported as metrics are decoded.
+
+type varz struct {
+	ServerID         string    `json:"server_id"`
+	ServerName       string    `json:"server_name"`
+	Version          string    `json:"version"`
+	Start            time.Time `json:"start"`
+	Now              time.Time `json:"now"`
+	Mem              int64     `json:"mem"`
+	Cores            int       `json:"cores"`
+	CPU              float64   `json:"cpu"`
+	Connections      int       `json:"connections"`
+	TotalConnections uint64    `json:"total_connections"`
+	Routes           int       `json:"routes"`
+	Remotes          int       `json:"remotes"`
+	Leafnodes        int       `json:"leafnodes"`
+	InMsgs           int64     `json:"in_msgs"`
+	OutMsgs          int64     `json:"out_msgs"`
+	InBytes          int64     `json:"in_bytes"`
+	OutBytes         int64     `json:"out_bytes"`
+	SlowConsumers    int64     `json:"slow_consumers"`
+	Subscriptions    uint32    `json:"subscriptions"`
+	JetStream        struct {
+		Stats *struct {
+			Memory   uint64 `json:"memory"`
+			Storage  uint64 `json:"storage"`
+			Accounts int    `json:"accounts"`
+			HAAssets int    `json:"ha_assets"`
+			API      struct {
+				Total  uint64 `json:"total"`
+				Errors uint64 `json:"errors"`
+			} `json:"api"`
+		} `json:"stats,omitempty"`
+	} `json:"jetstream"`
+}
+
+type connz struct {
+	NumConns int        `json:"num_connections"`
+	Total    int        `json:"total"`
+	Conns    []connInfo `json:"connections"`
+}
+
+type connInfo struct {
+	Account       string `json:"account"`
+	InMsgs        int64  `json:"in_msgs"`
+	OutMsgs       int64  `json:"out_msgs"`
+	InBytes       int64  `json:"in_bytes"`
+	OutBytes      int64  `json:"out_bytes"`
+	PendingBytes  int    `json:"pending_bytes"`
+	Subscriptions uint32 `json:"subscriptions"`
+}
+
+type jsz struct {
+	Memory    uint64          `json:"memory"`
+	Storage   uint64          `json:"storage"`
+	Streams   int             `json:"streams"`
+	Consumers int             `json:"consumers"`
+	Messages  uint64          `json:"messages"`
+	Bytes     uint64          `json:"bytes"`
+	Accounts  []accountDetail `json:"account_details"`
+}
+
+type accountDetail struct {
+	Name    string         `json:"name"`
+	Memory  uint64         `json:"memory"`
+	Storage uint64         `json:"storage"`
+	Streams []streamDetail `json:"stream_detail"`
+}
+
+type streamDetail struct {
+	Name  string `json:"name"`
+	State struct {
+		Messages  uint64 `json:"messages"`
+		Bytes     uint64 `json:"bytes"`
+		Consumers int    `json:"consumer_count"`
+	} `json:"state"`
+}
+
+type leafz struct {
+	Leafnodes int        `json:"leafnodes"`
+	Leafs     []leafInfo `json:"leafs"`
+}
+
+type leafInfo struct {
+	CID           uint64 `json:"id"`
+	Name          string `json:"name"`
+	Account       string `json:"account"`
+	InMsgs        int64  `json:"in_msgs"`
+	OutMsgs       int64  `json:"out_msgs"`
+	InBytes       int64  `json:"in_bytes"`
+	OutBytes      int64  `json:"out_bytes"`
+	Subscriptions uint32 `json:"subscriptions"`
+}
+
+type routez struct {
+	NumRoutes int         `json:"num_routes"`
+	Routes    []routeInfo `json:"routes"`
+}
+
+type routeInfo struct {
+	RemoteID      string `json:"remote_id"`
+	RemoteName    string `json:"remote_name"`
+	InMsgs        int64  `json:"in_msgs"`
+	OutMsgs       int64  `json:"out_msgs"`
+	InBytes       int64  `json:"in_bytes"`
+	OutBytes      int64  `json:"out_bytes"`
+	Pending       int    `json:"pending_size"`
+	Subscriptions uint32 `json:"subscriptions"`
+}
+
+type accountz struct {
+	SystemAccount string   `json:"system_account"`
+	Accounts      []string `json:"accounts"`
+}
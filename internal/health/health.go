@@ -0,0 +1,198 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datasance/nats-server/internal/metrics"
+	"github.com/datasance/nats-server/internal/status"
+	"github.com/datasance/nats-server/internal/watch"
+)
+
+const healthzTimeout = 3 * time.Second
+
+// Process reports whether the nats-server child process is running.
+type Process interface {
+	Running() bool
+}
+
+// Check is the result of one named check in a /livez or /readyz response.
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Response is the JSON body of /livez and /readyz.
+type Response struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+// Checker serves /livez and /readyz for the whole pipeline: the child process, nats-server's own /healthz
+// and the wrapper's sync/reload state.
+type Checker struct {
+	process Process
+	tracker *status.Tracker
+	// healthzURL is empty when the monitoring port is disabled; the healthz check is then skipped.
+	healthzURL string
+	// inputs must be met for the server to be ready.
+	inputs []watch.Requirement
+	// failOnExpired fails readiness while the last expiry scan found expired items.
+	failOnExpired bool
+	client        *http.Client
+}
+
+// New returns a Checker. monitorPort 0 skips the nats-server /healthz check. With jetStream false, /healthz is
+// queried with js-enabled-only=true (only checks that JetStream is enabled, not that every stream and consumer is
+// current). inputs are required for readiness (NATS_CONF, NATS_REQUIRED_INPUTS, the system user creds). With
+// failOnExpired, an expired certificate, JWT or creds found by the last expiry scan fails readiness; otherwise it is
+// only reported.
+func New(process Process, tracker *status.Tracker, monitorPort int, jetStream bool, inputs []watch.Requirement, failOnExpired bool) *Checker {
+	c := &Checker{
+		process:       process,
+		tracker:       tracker,
+		inputs:        inputs,
+		failOnExpired: failOnExpired,
+		client:        &http.Client{Timeout: healthzTimeout},
+	}
+	if monitorPort > 0 {
+		c.healthzURL = "http://127.0.0.1:" + strconv.Itoa(monitorPort) + "/healthz"
+		if !jetStream {
+			c.healthzURL += "?js-enabled-only=true"
+		}
+	}
+	return c
+}
+
+// Livez reports whether the wrapper is alive: the child is running, or is being restarted by the wrapper.
+// A crashed child makes the wrapper exit, so liveness only fails when the child is down without a restart pending.
+func (c *Checker) Livez(w http.ResponseWriter, _ *http.Request) {
+	snap := c.tracker.Snapshot()
+	checks := []Check{c.processCheck(snap)}
+	write(w, checks)
+}
+
+// Readyz reports whether the broker can serve traffic with the current inputs: the child is running and not
+// restarting or stopping, nats-server /healthz is ok, required inputs are met, the last JWT sync and reload succeeded,
+// and (with failOnExpired) nothing has expired. A reload rejected by nats-server fails readiness until a later reload
+// succeeds.
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	snap := c.tracker.Snapshot()
+	checks := []Check{c.processCheck(snap)}
+	if snap.Restarting {
+		checks = append(checks, Check{Name: "restart", OK: false, Detail: "nats-server is being restarted by the wrapper"})
+	}
+	if snap.Stopping {
+		checks = append(checks, Check{Name: "stop", OK: false, Detail: "nats-server is being stopped (lame duck mode)"})
+	}
+	if c.healthzURL != "" {
+		checks = append(checks, c.healthzCheck(r))
+	}
+	checks = append(checks, c.inputsCheck())
+	checks = append(checks, resultCheck("jwt_sync", snap.LastSync), reloadCheck(snap.LastReload))
+	if !snap.Expiry.Time.IsZero() {
+		checks = append(checks, c.expiryCheck(snap.Expiry))
+	}
+	write(w, checks)
+}
+
+func (c *Checker) processCheck(snap status.Snapshot) Check {
+	switch {
+	case c.process.Running():
+		return Check{Name: "process", OK: true}
+	case snap.Restarting:
+		return Check{Name: "process", OK: true, Detail: "restarting"}
+	}
+	return Check{Name: "process", OK: false, Detail: "nats-server is not running"}
+}
+
+func (c *Checker) healthzCheck(r *http.Request) Check {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, c.healthzURL, nil)
+	if err != nil {
+		return Check{Name: "healthz", OK: false, Detail: err.Error()}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Check{Name: "healthz", OK: false, Detail: err.Error()}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return Check{Name: "healthz", OK: false, Detail: fmt.Sprintf("%s: %s", resp.Status, strings.TrimSpace(string(body)))}
+	}
+	return Check{Name: "healthz", OK: true}
+}
+
+func (c *Checker) inputsCheck() Check {
+	var unmet []string
+	for _, r := range c.inputs {
+		if err := r.Check(); err != nil {
+			unmet = append(unmet, r.String()+" ("+err.Error()+")")
+		}
+	}
+	if len(unmet) > 0 {
+		return Check{Name: "inputs", OK: false, Detail: "unmet: " + strings.Join(unmet, ", ")}
+	}
+	return Check{Name: "inputs", OK: true}
+}
+
+// expiryCheck reports expired and expiring items; it fails only on expired items with failOnExpired.
+func (c *Checker) expiryCheck(e status.Expiry) Check {
+	var details []string
+	if len(e.Expired) > 0 {
+		details = append(details, "expired: "+strings.Join(e.Expired, ", "))
+	}
+	if len(e.Expiring) > 0 {
+		details = append(details, "expiring: "+strings.Join(e.Expiring, ", "))
+	}
+	return Check{Name: "expiry", OK: len(e.Expired) == 0 || !c.failOnExpired, Detail: strings.Join(details, "; ")}
+}
+
+// reloadCheck is resultCheck for the last reload, saying when nats-server rejected it and kept its previous config.
+func reloadCheck(r status.Result) Check {
+	if r.Outcome == metrics.OutcomeRejected {
+		return Check{Name: "reload", OK: false, Detail: fmt.Sprintf("rejected at %s, previous configuration kept: %s", r.Time.Format(time.RFC3339), r.Error)}
+	}
+	return resultCheck("reload", r)
+}
+
+func resultCheck(name string, r status.Result) Check {
+	if r.OK() {
+		return Check{Name: name, OK: true}
+	}
+	return Check{Name: name, OK: false, Detail: fmt.Sprintf("failed at %s: %s", r.Time.Format(time.RFC3339), r.Error)}
+}
+
+// write sends 200 with status "ok" if every check passed, 503 with status "fail" otherwise.
+func write(w http.ResponseWriter, checks []Check) {
+	resp := Response{Status: "ok", Checks: checks}
+	code := http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			resp.Status = "fail"
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
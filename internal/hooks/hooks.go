@@ -0,0 +1,165 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
+)
+
+var logger = logging.Component("hooks")
+
+// Point is a place in the reload pipeline where a hook runs.
+type Point string
+
+// Hook points, in pipeline order. Pre-hooks can veto the step that follows them.
+const (
+	// PreValidate runs first after the debounce, before the wrapper inspects the changed inputs; a veto drops the run.
+	PreValidate Point = "pre-validate"
+	// PostSync runs after every mount to JWT dir sync.
+	PostSync Point = "post-sync"
+	// PreReload runs before nats-server is reloaded or (leaf mode) restarted; a veto skips the reload or restart.
+	PreReload Point = "pre-reload"
+	// PostReload runs after the reload or restart with its outcome.
+	PostReload Point = "post-reload"
+	// PrePurge runs before JetStream data of removed accounts is purged; a veto skips the purge.
+	PrePurge Point = "pre-purge"
+)
+
+// maxOutput bounds the hook output kept for the log.
+const maxOutput = 4096
+
+// Input describes the pipeline step to a hook. It is written as JSON to the hook's stdin; the scalar fields are also
+// set as POT_HOOK_* environment variables (lists comma-separated).
+type Input struct {
+	Hook     Point          `json:"hook"`
+	Causes   []string       `json:"causes,omitempty"`
+	Files    []string       `json:"files,omitempty"`
+	Action   string         `json:"action,omitempty"`
+	Outcome  string         `json:"outcome,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Accounts []string       `json:"accounts,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+}
+
+// VetoError is returned by Run when a pre-hook exits non-zero or times out.
+type VetoError struct {
+	Hook Point
+	Err  error
+}
+
+func (e *VetoError) Error() string {
+	return fmt.Sprintf("vetoed by %s hook: %v", e.Hook, e.Err)
+}
+
+func (e *VetoError) Unwrap() error {
+	return e.Err
+}
+
+// Runner runs the configured hook commands. A nil Runner runs nothing.
+type Runner struct {
+	commands map[Point][]string
+	timeout  time.Duration
+}
+
+// New returns a Runner for commands (argv per point; points without a command are skipped) with a timeout per run.
+func New(commands map[Point][]string, timeout time.Duration) *Runner {
+	return &Runner{commands: commands, timeout: timeout}
+}
+
+// Enabled reports whether a command is configured for p.
+func (r *Runner) Enabled(p Point) bool {
+	return r != nil && len(r.commands[p]) > 0
+}
+
+// Run runs the hook for in.Hook, if configured, and waits for it up to the timeout. Output is logged. For pre-hooks
+// a failure (non-zero exit, timeout, start error) is returned as *VetoError; for post-hooks failures are logged and
+// nil is returned.
+func (r *Runner) Run(ctx context.Context, in Input) error {
+	if !r.Enabled(in.Hook) {
+		return nil
+	}
+	argv := r.commands[in.Hook]
+	stdin, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Env = append(os.Environ(), environ(in)...)
+	var out limitedBuffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	start := time.Now()
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", r.timeout)
+	}
+	attrs := []any{"hook", in.Hook, "command", argv[0], logging.KeyDuration, time.Since(start).Round(time.Millisecond)}
+	if output := strings.TrimSpace(out.String()); output != "" {
+		attrs = append(attrs, "output", output)
+	}
+	if err == nil {
+		metrics.Hooks.Inc(string(in.Hook), metrics.OutcomeSuccess)
+		logger.Info("Hook succeeded", attrs...)
+		return nil
+	}
+	metrics.Hooks.Inc(string(in.Hook), metrics.OutcomeFailure)
+	logger.Error("Hook failed", append(attrs, logging.Err(err))...)
+	if isPre(in.Hook) {
+		return &VetoError{Hook: in.Hook, Err: err}
+	}
+	return nil
+}
+
+func isPre(p Point) bool {
+	return strings.HasPrefix(string(p), "pre-")
+}
+
+// environ returns the POT_HOOK_* variables for in.
+func environ(in Input) []string {
+	return []string{
+		"POT_HOOK=" + string(in.Hook),
+		"POT_HOOK_CAUSES=" + strings.Join(in.Causes, ","),
+		"POT_HOOK_FILES=" + strings.Join(in.Files, ","),
+		"POT_HOOK_ACTION=" + in.Action,
+		"POT_HOOK_OUTCOME=" + in.Outcome,
+		"POT_HOOK_ERROR=" + in.Error,
+		"POT_HOOK_ACCOUNTS=" + strings.Join(in.Accounts, ","),
+	}
+}
+
+// limitedBuffer keeps the first maxOutput bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxOutput - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
@@ -15,12 +15,14 @@ package jspurge
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/nats-io/nats.go"
+	"github.com/datasance/nats-server/internal/natsclient"
+	"github.com/datasance/nats-server/internal/validate"
 )
 
 const (
@@ -112,14 +114,15 @@ func readDirNames(dir string) ([]string, error) {
 	return names, nil
 }
 
-// PurgeAccount calls the JetStream Account Purge API for the given account using system credentials.
+// PurgeAccount calls the JetStream Account Purge API for the given account using system credentials
+// (and the client TLS/nkey/token options from natsclient). It refuses to connect if the creds fail
+// validate.CredsError against jwtDir.
 // Subject: $JS.API.ACCOUNT.PURGE.{accountName}, body: {}. Returns nil if the server reports success (initiated: true or no error).
-func PurgeAccount(ctx context.Context, natsURL, credsPath, accountName string) error {
-	var opts []nats.Option
-	if credsPath != "" {
-		opts = append(opts, nats.UserCredentials(credsPath))
+func PurgeAccount(ctx context.Context, natsURL, credsPath, jwtDir, accountName string) error {
+	if err := validate.CredsError(credsPath, jwtDir, time.Now()); err != nil {
+		return fmt.Errorf("system creds: %w", err)
 	}
-	nc, err := nats.Connect(natsURL, opts...)
+	nc, err := natsclient.Connect(natsURL, credsPath)
 	if err != nil {
 		return err
 	}
@@ -146,6 +149,17 @@ func PurgeAccount(ctx context.Context, natsURL, credsPath, accountName string) e
 	return nil
 }
 
+// Reconcile is the outcome of one JetStream account reconciliation: the accounts ToPurge selected and, unless
+// DryRun, the purges initiated or failed (account -> error).
+type Reconcile struct {
+	StoreDir         string            `json:"store_dir"`
+	ResolverAccounts int               `json:"resolver_accounts"`
+	ToPurge          []string          `json:"to_purge"`
+	DryRun           bool              `json:"dry_run"`
+	Initiated        []string          `json:"initiated,omitempty"`
+	Failed           map[string]string `json:"failed,omitempty"`
+}
+
 // ToPurge returns account names that are in accountsWithJS but not in currentResolver.
 // These are the accounts that should be purged (removed from resolver but still have JS data).
 func ToPurge(accountsWithJS, currentResolver []string) []string {
@@ -13,42 +13,89 @@
 package jwtcopy
 
 import (
-	"io"
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+// Stats counts what a SyncMountToJWT run changed in the JWT dir.
+type Stats struct {
+	// Added are JWT files that did not exist in the JWT dir.
+	Added int
+	// Updated are JWT files whose content differed and was overwritten.
+	Updated int
+	// Unchanged are JWT files already identical in the JWT dir (not rewritten).
+	Unchanged int
+	// Removed are JWT files in the JWT dir that are no longer in the mount dir.
+	Removed int
+}
+
+// Changed reports whether the run added, updated or removed any file.
+func (s Stats) Changed() bool {
+	return s.Added > 0 || s.Updated > 0 || s.Removed > 0
+}
+
 // SyncMountToJWT makes NATS_JWT_DIR mirror NATS_JWT_MOUNT_DIR: copies all *.jwt files
-// from mountDir to jwtDir (overwrite), then removes any *.jwt in jwtDir not in mountDir.
+// from mountDir to jwtDir (overwrite when content differs), then removes any *.jwt in jwtDir not in mountDir.
 // No directory renames—only file copy and remove—so it is safe when jwtDir is a volume
 // mount (no cross-device link). If mountDir and jwtDir are the same path, skips and
-// returns 0, 0, nil. If mountDir does not exist, returns 0, 0, nil. Empty mount list
+// returns zero Stats. If mountDir does not exist, returns zero Stats. Empty mount list
 // returns without removing anything (e.g. K8s ConfigMap rotation).
-func SyncMountToJWT(mountDir, jwtDir string) (copied int, removed int, err error) {
+func SyncMountToJWT(mountDir, jwtDir string) (Stats, error) {
+	return syncMount(mountDir, jwtDir, true)
+}
+
+// Compare returns what SyncMountToJWT would change in jwtDir, without changing it.
+func Compare(mountDir, jwtDir string) (Stats, error) {
+	return syncMount(mountDir, jwtDir, false)
+}
+
+// syncMount counts the differences between mountDir and jwtDir and, with write, makes jwtDir mirror mountDir.
+func syncMount(mountDir, jwtDir string, write bool) (stats Stats, err error) {
 	if filepath.Clean(mountDir) == filepath.Clean(jwtDir) {
-		return 0, 0, nil
+		return stats, nil
 	}
 	mountNames, err := listJWTFileNames(mountDir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return 0, 0, nil
+			return stats, nil
 		}
-		return 0, 0, err
+		return stats, err
 	}
 	if len(mountNames) == 0 {
-		return 0, 0, nil
+		return stats, nil
 	}
-	if err := os.MkdirAll(jwtDir, 0755); err != nil {
-		return 0, 0, err
+	if write {
+		if err := os.MkdirAll(jwtDir, 0755); err != nil {
+			return stats, err
+		}
 	}
 	for _, name := range mountNames {
 		src := filepath.Join(mountDir, name)
 		dst := filepath.Join(jwtDir, name)
-		if err := copyFile(src, dst); err != nil {
-			return copied, removed, err
+		srcData, err := os.ReadFile(src)
+		if err != nil {
+			return stats, err
+		}
+		dstData, err := os.ReadFile(dst)
+		switch {
+		case err == nil && bytes.Equal(srcData, dstData):
+			stats.Unchanged++
+			continue
+		case err == nil:
+			stats.Updated++
+		case os.IsNotExist(err):
+			stats.Added++
+		default:
+			return stats, err
+		}
+		if !write {
+			continue
+		}
+		if err := writeFile(dst, srcData); err != nil {
+			return stats, err
 		}
-		copied++
 	}
 	mountSet := make(map[string]struct{}, len(mountNames))
 	for _, n := range mountNames {
@@ -56,18 +103,25 @@ func SyncMountToJWT(mountDir, jwtDir string) (copied int, removed int, err error
 	}
 	jwtNames, err := listJWTFileNames(jwtDir)
 	if err != nil {
-		return copied, removed, err
+		if !write && os.IsNotExist(err) {
+			return stats, nil
+		}
+		return stats, err
 	}
 	for _, name := range jwtNames {
 		if _, inMount := mountSet[name]; inMount {
 			continue
 		}
+		if !write {
+			stats.Removed++
+			continue
+		}
 		if err := os.Remove(filepath.Join(jwtDir, name)); err != nil && !os.IsNotExist(err) {
-			return copied, removed, err
+			return stats, err
 		}
-		removed++
+		stats.Removed++
 	}
-	return copied, removed, nil
+	return stats, nil
 }
 
 // listJWTFileNames returns base names of *.jwt files in dir, skipping *.jwt.delete.
@@ -94,19 +148,13 @@ func listJWTFileNames(dir string) ([]string, error) {
 	return names, nil
 }
 
-func copyFile(src, dst string) error {
-	in, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer in.Close()
+func writeFile(dst string, data []byte) error {
 	out, err := os.Create(dst)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
-	_, err = io.Copy(out, in)
-	if err != nil {
+	if _, err := out.Write(data); err != nil {
 		return err
 	}
 	return out.Sync()
@@ -0,0 +1,148 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Attribute keys shared by all wrapper log records, so log pipelines can filter on them.
+const (
+	KeyComponent = "component"
+	KeyCause     = "cause"
+	KeyAccount   = "account"
+	KeyDuration  = "duration"
+	KeyError     = "error"
+	KeySource    = "source"
+	// KeyNatsLevel is the original nats-server level (INF, ERR, FTL, ...) of forwarded lines.
+	KeyNatsLevel = "nats_level"
+)
+
+// SourceNatsServer is the source attribute of lines forwarded from the nats-server child.
+const SourceNatsServer = "nats-server"
+
+// Setup installs the default slog logger writing to w, in JSON when format is "json" and in logfmt text otherwise,
+// at the given level ("debug", "info", "warn", "error"; default info). Packages log through loggers returned by
+// Component, which follow the default logger, so Setup may run after package initialization. The last ringSize
+// records, of any level, are also kept in the returned Ring.
+func Setup(w io.Writer, format, level string, ringSize int) *Ring {
+	opts := &slog.HandlerOptions{Level: ParseLevel(level)}
+	var h slog.Handler
+	if strings.EqualFold(strings.TrimSpace(format), "json") {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	ring := NewRing(ringSize)
+	slog.SetDefault(slog.New(&ringHandler{next: h, ring: ring}))
+	return ring
+}
+
+// ParseLevel parses a level name; unknown or empty names return slog.LevelInfo.
+func ParseLevel(s string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug", "trace":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// Component returns a logger that tags records with component=name and writes through whatever slog.Default is
+// at the time of each call.
+func Component(name string) *slog.Logger {
+	return slog.New(&defaultHandler{}).With(KeyComponent, name)
+}
+
+// Source returns a logger for output forwarded from another process, tagged with source=name instead of a
+// component. Like Component, it writes through whatever slog.Default is at the time of each call.
+func Source(name string) *slog.Logger {
+	return slog.New(&defaultHandler{}).With(KeySource, name)
+}
+
+// Err returns the error attribute for err.
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.String(KeyError, "")
+	}
+	return slog.String(KeyError, err.Error())
+}
+
+var (
+	fatalMu   sync.Mutex
+	fatalHook func(msg string)
+)
+
+// OnFatal registers fn to run in Fatal after msg is logged and before the process exits, e.g. to write a
+// termination message.
+func OnFatal(fn func(msg string)) {
+	fatalMu.Lock()
+	fatalHook = fn
+	fatalMu.Unlock()
+}
+
+// Fatal logs msg at error level on l, runs the OnFatal hook and exits with status 1.
+func Fatal(l *slog.Logger, msg string, args ...any) {
+	l.Error(msg, args...)
+	fatalMu.Lock()
+	fn := fatalHook
+	fatalMu.Unlock()
+	if fn != nil {
+		fn(msg)
+	}
+	os.Exit(1)
+}
+
+// defaultHandler delegates to slog.Default().Handler() at log time, replaying the attrs and groups added with
+// WithAttrs/WithGroup in order.
+type defaultHandler struct {
+	ops []func(slog.Handler) slog.Handler
+}
+
+func (h *defaultHandler) resolve() slog.Handler {
+	out := slog.Default().Handler()
+	for _, op := range h.ops {
+		out = op(out)
+	}
+	return out
+}
+
+func (h *defaultHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (h *defaultHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.resolve().Handle(ctx, r)
+}
+
+func (h *defaultHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *defaultHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *defaultHandler) with(op func(slog.Handler) slog.Handler) slog.Handler {
+	ops := make([]func(slog.Handler) slog.Handler, 0, len(h.ops)+1)
+	ops = append(ops, h.ops...)
+	return &defaultHandler{ops: append(ops, op)}
+}
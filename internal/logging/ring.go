@@ -0,0 +1,115 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Entry is one log record kept in a Ring.
+type Entry struct {
+	Time  time.Time
+	Level slog.Level
+	// Origin is the component (wrapper) or source (e.g. nats-server) of the record.
+	Origin string
+	// NatsLevel is set for lines forwarded from nats-server.
+	NatsLevel string
+	Message   string
+	Error     string
+}
+
+// Ring keeps the last N log records in memory, e.g. to explain a crash in the termination message.
+// Safe for concurrent use.
+type Ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// NewRing returns a Ring holding up to size entries (at least 1).
+func NewRing(size int) *Ring {
+	return &Ring{entries: make([]Entry, max(size, 1))}
+}
+
+// Add appends e, dropping the oldest entry when the ring is full.
+func (r *Ring) Add(e Entry) {
+	r.mu.Lock()
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+}
+
+// Entries returns the kept entries, oldest first.
+func (r *Ring) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]Entry(nil), r.entries[:r.next]...)
+	}
+	out := make([]Entry, 0, len(r.entries))
+	out = append(out, r.entries[r.next:]...)
+	return append(out, r.entries[:r.next]...)
+}
+
+// ringHandler records every handled record in a Ring and passes it on to next.
+type ringHandler struct {
+	next  slog.Handler
+	ring  *Ring
+	attrs []slog.Attr
+}
+
+// Enabled reports true for every level so the ring keeps records below the configured log level as well.
+func (h *ringHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *ringHandler) Handle(ctx context.Context, r slog.Record) error {
+	e := Entry{Time: r.Time, Level: r.Level, Message: r.Message}
+	collect := func(a slog.Attr) bool {
+		switch a.Key {
+		case KeyComponent, KeySource:
+			e.Origin = a.Value.String()
+		case KeyNatsLevel:
+			e.NatsLevel = a.Value.String()
+		case KeyError:
+			e.Error = a.Value.String()
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		collect(a)
+	}
+	r.Attrs(collect)
+	h.ring.Add(e)
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ringHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	all := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	all = append(all, h.attrs...)
+	return &ringHandler{next: h.next.WithAttrs(attrs), ring: h.ring, attrs: append(all, attrs...)}
+}
+
+func (h *ringHandler) WithGroup(name string) slog.Handler {
+	return &ringHandler{next: h.next.WithGroup(name), ring: h.ring, attrs: h.attrs}
+}
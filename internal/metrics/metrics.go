@@ -0,0 +1,259 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector writes metric families in the Prometheus text exposition format when the registry is scraped.
+// Counters, gauges and gauge funcs below are collectors; other packages may register their own (e.g. to
+// export values fetched at scrape time).
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// Registry holds the collectors exposed on /metrics.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// Default is the registry the package-level metrics are registered in and Handler serves.
+var Default = &Registry{}
+
+// Register adds c to the registry.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// WriteTo writes all registered collectors to out in the Prometheus text format.
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+	w := &Writer{bw: bufio.NewWriter(out)}
+	for _, c := range collectors {
+		c.Collect(w)
+	}
+	err := w.bw.Flush()
+	return w.n, err
+}
+
+// Handler returns an http.Handler serving the Default registry in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = Default.WriteTo(rw)
+	})
+}
+
+// Writer writes metric families in the Prometheus text exposition format.
+type Writer struct {
+	bw *bufio.Writer
+	n  int64
+}
+
+// Family writes the HELP and TYPE lines of a metric family. typ is "counter", "gauge" or "untyped".
+func (w *Writer) Family(name, help, typ string) {
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+// Sample writes one sample of the current family. labels and values must have the same length.
+func (w *Writer) Sample(name string, labels, values []string, v float64) {
+	w.printf("%s%s %s\n", name, formatLabels(labels, values), formatValue(v))
+}
+
+func (w *Writer) printf(format string, args ...any) {
+	n, _ := fmt.Fprintf(w.bw, format, args...)
+	w.n += int64(n)
+}
+
+// vec holds the samples of a labelled metric, keyed by their joined label values.
+type vec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	v           float64
+}
+
+func newVec(name, help, typ string, labels []string) *vec {
+	return &vec{name: name, help: help, typ: typ, labels: labels, values: make(map[string]*sample)}
+}
+
+func (v *vec) update(fn func(float64) float64, labelValues []string) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	s, ok := v.values[key]
+	if !ok {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		v.values[key] = s
+	}
+	s.v = fn(s.v)
+	v.mu.Unlock()
+}
+
+// Collect implements Collector. Samples are written sorted by label values for stable output.
+func (v *vec) Collect(w *Writer) {
+	v.mu.Lock()
+	samples := make([]sample, 0, len(v.values))
+	for _, s := range v.values {
+		samples = append(samples, *s)
+	}
+	v.mu.Unlock()
+	if len(samples) == 0 && len(v.labels) > 0 {
+		return
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].labelValues, "\xff") < strings.Join(samples[j].labelValues, "\xff")
+	})
+	w.Family(v.name, v.help, v.typ)
+	if len(samples) == 0 {
+		// Unlabelled metrics are always exposed, starting at zero.
+		w.Sample(v.name, nil, nil, 0)
+		return
+	}
+	for _, s := range samples {
+		w.Sample(v.name, v.labels, s.labelValues, s.v)
+	}
+}
+
+// CounterVec is a counter partitioned by labels. A CounterVec without labels is a plain counter.
+type CounterVec struct{ *vec }
+
+// NewCounterVec creates a counter and registers it in the Default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, "counter", labels)}
+	Default.Register(c)
+	return c
+}
+
+// Inc increments the counter for the given label values by 1.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by delta (negative deltas are ignored).
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.update(func(v float64) float64 { return v + delta }, labelValues)
+}
+
+// GaugeVec is a gauge partitioned by labels. A GaugeVec without labels is a plain gauge.
+type GaugeVec struct{ *vec }
+
+// NewGaugeVec creates a gauge and registers it in the Default registry.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, "gauge", labels)}
+	Default.Register(g)
+	return g
+}
+
+// Set sets the gauge for the given label values.
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.update(func(float64) float64 { return v }, labelValues)
+}
+
+// Reset removes all samples, e.g. before setting a new info value.
+func (g *GaugeVec) Reset() {
+	g.mu.Lock()
+	g.values = make(map[string]*sample)
+	g.mu.Unlock()
+}
+
+// GaugeFunc is an unlabelled gauge whose value is computed at scrape time.
+type GaugeFunc struct {
+	name, help string
+	fn         func() (float64, bool)
+}
+
+// NewGaugeFunc creates a gauge computed by fn at scrape time and registers it in the Default registry.
+// The sample is omitted while fn returns false (e.g. no value yet).
+func NewGaugeFunc(name, help string, fn func() (float64, bool)) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, fn: fn}
+	Default.Register(g)
+	return g
+}
+
+// Collect implements Collector.
+func (g *GaugeFunc) Collect(w *Writer) {
+	v, ok := g.fn()
+	if !ok {
+		return
+	}
+	w.Family(g.name, g.help, "gauge")
+	w.Sample(g.name, nil, nil, v)
+}
+
+func formatLabels(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
@@ -0,0 +1,113 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package metrics
+
+import (
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+)
+
+// Outcomes used as the "outcome" label of Reloads and the other outcome counters.
+const (
+	OutcomeSuccess     = "success"
+	OutcomeFailure     = "failure"
+	OutcomeRestart     = "restart"
+	OutcomeUnchanged   = "unchanged"
+	OutcomeRejected    = "rejected"
+	OutcomeUnconfirmed = "unconfirmed"
+	OutcomeDropped     = "dropped"
+	OutcomeVetoed      = "vetoed"
+	OutcomeDeferred    = "deferred"
+)
+
+// Wrapper (control plane) metrics. All names are prefixed pot_nats_.
+var (
+	Reloads = NewCounterVec("pot_nats_reloads_total",
+		"Reload decisions of the wrapper by cause (config, accounts, ssl, jwt, creds) and outcome (success, failure, rejected, unconfirmed, restart, unchanged, vetoed, deferred).",
+		"cause", "outcome")
+	LeafRestarts = NewCounterVec("pot_nats_leaf_restarts_total",
+		"Restarts of nats-server in leaf mode requested by the reload policy to load a changed config, accounts or creds; admin restarts are not counted.")
+	ChildCrashes = NewCounterVec("pot_nats_child_crashes_total",
+		"Unexpected exits of the nats-server child process.")
+	JWTSyncFiles = NewCounterVec("pot_nats_jwt_sync_files_total",
+		"Account JWT files changed by the mount to JWT dir sync, by operation (added, updated, removed).",
+		"op")
+	JWTSyncs = NewCounterVec("pot_nats_jwt_syncs_total",
+		"Mount to JWT dir sync runs by outcome (success, failure).",
+		"outcome")
+	ClaimsPushes = NewCounterVec("pot_nats_claims_push_total",
+		"Account JWTs pushed via $SYS.REQ.CLAIMS.UPDATE by outcome (success, failure).",
+		"outcome")
+	PurgesInitiated = NewCounterVec("pot_nats_jetstream_purges_initiated_total",
+		"JetStream account purges initiated for accounts removed from the resolver.")
+	PurgesCompleted = NewCounterVec("pot_nats_jetstream_purges_completed_total",
+		"JetStream account purges whose account data is gone from the store.")
+	WatchdogProbeFailures = NewCounterVec("pot_nats_watchdog_probe_failures_total",
+		"Failed watchdog probes of nats-server by probe (healthz, client).",
+		"probe")
+	WatchdogRestarts = NewCounterVec("pot_nats_watchdog_restarts_total",
+		"Restarts of a wedged nats-server by the watchdog.")
+	WatcherErrors = NewCounterVec("pot_nats_watcher_errors_total",
+		"Errors reported by file and directory watchers, by watcher kind (file, dir).",
+		"watcher")
+	WatcherRestarts = NewCounterVec("pot_nats_watcher_restarts_total",
+		"Watchers restarted after they stopped, by watcher (config, accounts, ssl, jwt, creds).",
+		"watcher")
+	Resyncs = NewCounterVec("pot_nats_resyncs_total",
+		"Full resyncs of the wrapper inputs by trigger (interval, overflow, watcher_exit) and outcome (success, failure).",
+		"trigger", "outcome")
+	ChildLogLines = NewCounterVec("pot_nats_child_log_lines_total",
+		"Lines logged by nats-server by level (TRC, DBG, INF, WRN, ERR, FTL; raw for lines not in the log format).",
+		"level")
+	Events = NewCounterVec("pot_nats_events_total",
+		"Lifecycle events handled by each event sink (nats, webhook) by outcome (success, failure, dropped).",
+		"sink", "outcome")
+	Hooks = NewCounterVec("pot_nats_hooks_total",
+		"Runs of user-defined pipeline hooks by hook (pre-validate, post-sync, pre-reload, post-reload, pre-purge) and outcome (success, failure).",
+		"hook", "outcome")
+	ExpiryDays = NewGaugeVec("pot_nats_expiry_days",
+		"Days until a certificate, account JWT or creds user JWT expires (negative once expired), by kind (cert, jwt, creds), path and subject.",
+		"kind", "path", "subject")
+	ConfigInfo = NewGaugeVec("pot_nats_config_info",
+		"Hash of the server config file currently loaded by nats-server; always 1.",
+		"path", "sha256")
+
+	lastReloadSuccess atomic.Int64
+	_                 = NewGaugeFunc("pot_nats_last_reload_success_timestamp_seconds",
+		"Unix time of the last successful reload or restart of nats-server.",
+		func() (float64, bool) {
+			t := lastReloadSuccess.Load()
+			return float64(t), t > 0
+		})
+	_ = NewGaugeFunc("pot_nats_seconds_since_last_reload_success",
+		"Seconds since the last successful reload or restart of nats-server.",
+		func() (float64, bool) {
+			t := lastReloadSuccess.Load()
+			if t == 0 {
+				return 0, false
+			}
+			return time.Since(time.Unix(t, 0)).Seconds(), true
+		})
+)
+
+// ReloadSucceeded records the time of a successful reload or (re)start of nats-server.
+func ReloadSucceeded() {
+	lastReloadSuccess.Store(time.Now().Unix())
+}
+
+// SetConfigHash replaces the config info sample with the given file path and SHA256.
+func SetConfigHash(path string, sum [32]byte) {
+	ConfigInfo.Reset()
+	ConfigInfo.Set(1, path, hex.EncodeToString(sum[:]))
+}
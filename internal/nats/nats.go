@@ -13,21 +13,66 @@
 package nats
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os/exec"
+	"log/slog"
+	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/datasance/nats-server/internal/config"
 	execpkg "github.com/datasance/nats-server/internal/exec"
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
+	"github.com/datasance/nats-server/internal/natsclient"
+	"github.com/datasance/nats-server/internal/natslog"
 )
 
+const readyPollInterval = 250 * time.Millisecond
+
+var (
+	logger      = logging.Component("nats")
+	childLogger = logging.Source(logging.SourceNatsServer)
+)
+
+// Log messages nats-server writes when handling SIGHUP.
+const (
+	reloadedMsg     = "Reloaded server configuration"
+	reloadFailedMsg = "Failed to reload server configuration"
+)
+
+// ErrReloadUnconfirmed is returned by ReloadAndVerify when nats-server did not log the reload result in time
+// (e.g. logging to a file instead of stdout/stderr).
+var ErrReloadUnconfirmed = errors.New("reload result not seen in nats-server log")
+
+// ReloadRejectedError is returned by ReloadAndVerify when nats-server logs that it failed to reload; the server
+// keeps running with the previous configuration.
+type ReloadRejectedError struct {
+	// Message is nats-server's log message, including the reason.
+	Message string
+}
+
+func (e *ReloadRejectedError) Error() string {
+	return e.Message
+}
+
 type Server struct {
-	cmd *exec.Cmd
-	mu  sync.Mutex
+	cmd     *execpkg.Cmd
+	started time.Time
+	mu      sync.Mutex
+	logs    natslog.Hub
+}
+
+// Logs returns the hub of parsed nats-server output lines. Subscribers see every line of every process started
+// by this Server; they are called from the output reader goroutines and must not block.
+func (s *Server) Logs() *natslog.Hub {
+	return &s.logs
 }
 
 // Start starts nats-server with the given server config file path. The process environment
@@ -49,26 +94,180 @@ func (s *Server) Start(serverConfPath string, exitCh chan<- error) error {
 		args = append(args, "-m", strconv.Itoa(port))
 	}
 
-	cmd, err := execpkg.Start(bin, args, nil, workDir)
+	cmd, err := execpkg.Start(bin, args, nil, workDir, s.onLine)
 	if err != nil {
 		return fmt.Errorf("failed to start nats-server: %w", err)
 	}
 	s.cmd = cmd
+	s.started = time.Now()
 
+	// Wait returns once the output readers are done, so the exit is reported after the last line of the process.
 	go func() {
 		err := cmd.Wait()
 		s.mu.Lock()
 		s.cmd = nil
+		s.started = time.Time{}
 		s.mu.Unlock()
 		if exitCh != nil {
 			exitCh <- err
 		}
 	}()
 
-	log.Printf("NATS server started with config %s", serverConfPath)
+	logger.Info("NATS server started", "config", serverConfPath)
 	return nil
 }
 
+// Running reports whether the nats-server process has been started and has not exited yet.
+func (s *Server) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cmd != nil
+}
+
+// StartTime returns when the running nats-server process was started, or the zero time if it is not running.
+func (s *Server) StartTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
+}
+
+// Signal sends sig to the running nats-server process (e.g. SIGQUIT for a goroutine dump, SIGKILL when wedged).
+func (s *Server) Signal(sig os.Signal) error {
+	s.mu.Lock()
+	cmd := s.cmd
+	s.mu.Unlock()
+
+	if cmd == nil || cmd.Process == nil {
+		return fmt.Errorf("nats-server not running")
+	}
+	if err := cmd.Process.Signal(sig); err != nil {
+		return fmt.Errorf("failed to send %v: %w", sig, err)
+	}
+	logger.Info("Sent signal to nats-server", "signal", sig.String())
+	return nil
+}
+
+// onLine parses one line of nats-server output, forwards it to the wrapper log (source=nats-server, at the
+// matching level) and publishes it to the Logs hub.
+func (s *Server) onLine(stream, line string) {
+	e := natslog.Parse(stream, line)
+	metrics.ChildLogLines.Inc(string(levelOrRaw(e)))
+	attrs := []any{"stream", e.Stream}
+	if e.Parsed {
+		attrs = append(attrs, logging.KeyNatsLevel, string(e.Level), "pid", e.PID)
+	}
+	childLogger.Log(context.Background(), slogLevel(e.Level), e.Message, attrs...)
+	s.logs.Publish(e)
+}
+
+// levelOrRaw returns the event level, or "raw" for lines that are not in the log format (panics, goroutine dumps).
+func levelOrRaw(e natslog.Event) natslog.Level {
+	if !e.Parsed {
+		return "raw"
+	}
+	return e.Level
+}
+
+// slogLevel maps a nats-server level to a wrapper log level. Debug and trace lines stay at info: they are only
+// printed when enabled in the server config, so they are not filtered again by NATS_LOG_LEVEL.
+func slogLevel(l natslog.Level) slog.Level {
+	switch l {
+	case natslog.LevelWarn:
+		return slog.LevelWarn
+	case natslog.LevelError, natslog.LevelFatal:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// WaitReady blocks until a nats-server process started at or after startedAfter (zero: any) is running and
+// reports healthy, or returns an error when timeout expires or ctx is cancelled. Healthy means /healthz on
+// NATS_MONITOR_PORT answers 200; with monitoring disabled, a client connection to NATS_CLIENT_URL with the
+// system user credentials succeeds.
+func (s *Server) WaitReady(ctx context.Context, startedAfter time.Time, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		started := s.StartTime()
+		switch {
+		case started.IsZero():
+			lastErr = fmt.Errorf("nats-server not running")
+		case started.Before(startedAfter):
+			lastErr = fmt.Errorf("waiting for nats-server restart")
+		default:
+			if lastErr = probeReady(ctx); lastErr == nil {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("nats-server not ready after %s: %w", timeout, lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeReady checks nats-server health once; see WaitReady.
+func probeReady(ctx context.Context) error {
+	if port := config.GetNatsMonitorPort(); port > 0 {
+		url := "http://127.0.0.1:" + strconv.Itoa(port) + "/healthz?js-enabled-only=true"
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("healthz: %s", resp.Status)
+		}
+		return nil
+	}
+	nc, err := natsclient.Connect(config.GetNatsClientURL(), config.GetNatsSysUserCredPath())
+	if err != nil {
+		return err
+	}
+	nc.Close()
+	return nil
+}
+
+// ReloadAndVerify sends SIGHUP and waits up to timeout for nats-server to log the result: nil when it reports
+// the configuration reloaded, a *ReloadRejectedError when it rejects the new configuration,
+// and ErrReloadUnconfirmed when neither is logged in time.
+func (s *Server) ReloadAndVerify(timeout time.Duration) error {
+	result := make(chan error, 1)
+	unsubscribe := s.logs.Subscribe(func(e natslog.Event) {
+		var err error
+		switch {
+		case strings.Contains(e.Message, reloadedMsg):
+		case strings.Contains(e.Message, reloadFailedMsg):
+			err = &ReloadRejectedError{Message: e.Message}
+		default:
+			return
+		}
+		select {
+		case result <- err:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	if err := s.Reload(); err != nil {
+		return err
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return ErrReloadUnconfirmed
+	}
+}
+
 // Reload sends SIGHUP to the running nats-server process so it reloads config and certs.
 func (s *Server) Reload() error {
 	s.mu.Lock()
@@ -81,7 +280,24 @@ func (s *Server) Reload() error {
 	if err := cmd.Process.Signal(syscall.SIGHUP); err != nil {
 		return fmt.Errorf("failed to send SIGHUP: %w", err)
 	}
-	log.Printf("Sent SIGHUP to nats-server for config reload")
+	logger.Info("Sent SIGHUP to nats-server for config reload")
+	return nil
+}
+
+// LameDuck sends SIGUSR2 to put nats-server into lame duck mode: it stops accepting connections, closes existing
+// clients gradually over lame_duck_duration and then exits.
+func (s *Server) LameDuck() error {
+	s.mu.Lock()
+	cmd := s.cmd
+	s.mu.Unlock()
+
+	if cmd == nil || cmd.Process == nil {
+		return fmt.Errorf("nats-server not running")
+	}
+	if err := cmd.Process.Signal(syscall.SIGUSR2); err != nil {
+		return fmt.Errorf("failed to send SIGUSR2: %w", err)
+	}
+	logger.Info("Sent SIGUSR2 to nats-server for lame duck mode")
 	return nil
 }
 
@@ -98,6 +314,6 @@ func (s *Server) Stop() error {
 	if err := cmd.Process.Signal(syscall.SIGINT); err != nil {
 		return fmt.Errorf("failed to send SIGINT: %w", err)
 	}
-	log.Printf("Sent SIGINT to nats-server for graceful stop (restart)")
+	logger.Info("Sent SIGINT to nats-server for graceful stop (restart)")
 	return nil
 }
@@ -0,0 +1,125 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package natsclient
+
+import (
+	"crypto/tls"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/datasance/nats-server/internal/config"
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/nats-io/nats.go"
+)
+
+var logger = logging.Component("natsclient")
+
+const clientName = "pot-nats"
+
+// Long-lived connections registered with Track, by name.
+var (
+	trackedMu sync.Mutex
+	tracked   = make(map[*nats.Conn]string)
+)
+
+// Options returns the connection options for the wrapper's own NATS client connection: credsPath
+// (user credentials, skipped if empty), nkey seed, token and TLS settings from the NATS_CLIENT_* env vars.
+// Certificates and root CAs are read from disk on every TLS handshake, so rotation in NATS_SSL_DIR
+// is picked up by the next connect or reconnect without restarting the wrapper; connections that stay up
+// are registered with Track and reconnected by Reconnect.
+func Options(credsPath string) ([]nats.Option, error) {
+	opts := []nats.Option{nats.Name(clientName)}
+	if credsPath != "" {
+		opts = append(opts, nats.UserCredentials(credsPath))
+	}
+	if seed := config.GetNatsClientNkeySeedPath(); seed != "" {
+		opt, err := nats.NkeyOptionFromSeed(seed)
+		if err != nil {
+			return nil, fmt.Errorf("nkey seed %s: %w", seed, err)
+		}
+		opts = append(opts, opt)
+	}
+	if token := config.GetNatsClientToken(); token != "" {
+		opts = append(opts, nats.Token(token))
+	}
+	if !config.GetNatsClientTLS() {
+		return opts, nil
+	}
+
+	// Secure must come first so RootCAs/ClientCert keep the server name instead of creating their own tls.Config.
+	opts = append(opts, nats.Secure(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: config.GetNatsClientTLSServerName(),
+	}))
+	if ca := config.GetNatsClientTLSCAFile(); ca != "" {
+		opts = append(opts, nats.RootCAs(ca))
+	}
+	certFile, keyFile := config.GetNatsClientTLSCertFile(), config.GetNatsClientTLSKeyFile()
+	switch {
+	case certFile != "" && keyFile != "":
+		opts = append(opts, nats.ClientCert(certFile, keyFile))
+	case certFile != "" || keyFile != "":
+		return nil, fmt.Errorf("client TLS needs both cert and key (cert=%q key=%q)", certFile, keyFile)
+	}
+	if config.GetNatsClientTLSFirst() {
+		opts = append(opts, nats.TLSHandshakeFirst())
+	}
+	return opts, nil
+}
+
+// Connect connects to the NATS server at url using Options(credsPath).
+func Connect(url, credsPath string) (*nats.Conn, error) {
+	opts, err := Options(credsPath)
+	if err != nil {
+		return nil, err
+	}
+	return nats.Connect(url, opts...)
+}
+
+// Track registers the long-lived connection nc (name is for logs) for Reconnect. Closed connections are dropped.
+func Track(name string, nc *nats.Conn) {
+	trackedMu.Lock()
+	tracked[nc] = name
+	trackedMu.Unlock()
+}
+
+// Reconnect makes the tracked connections reconnect when client TLS is enabled, so their next handshake uses the
+// client certificate and root CAs now on disk, e.g. after a rotation in NATS_SSL_DIR.
+func Reconnect() {
+	if !config.GetNatsClientTLS() {
+		return
+	}
+	trackedMu.Lock()
+	defer trackedMu.Unlock()
+	for nc, name := range tracked {
+		if nc.IsClosed() {
+			delete(tracked, nc)
+			continue
+		}
+		if err := nc.ForceReconnect(); err != nil {
+			logger.Warn("Reconnect for rotated TLS material failed", "connection", name, logging.Err(err))
+			continue
+		}
+		logger.Info("Reconnecting for rotated TLS material", "connection", name)
+	}
+}
+
+// subjectTokenRE matches characters not allowed in a subject token built from a name.
+var subjectTokenRE = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// SubjectToken returns name as a single subject token (e.g. a server name in a subject), with characters other
+// than letters, digits, "_" and "-" replaced with "_".
+func SubjectToken(name string) string {
+	return subjectTokenRE.ReplaceAllString(name, "_")
+}
@@ -0,0 +1,113 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package natslog
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Level is the nats-server log level tag, e.g. "INF".
+type Level string
+
+const (
+	LevelTrace Level = "TRC"
+	LevelDebug Level = "DBG"
+	LevelInfo  Level = "INF"
+	LevelWarn  Level = "WRN"
+	LevelError Level = "ERR"
+	LevelFatal Level = "FTL"
+)
+
+// timeLayout is the nats-server log timestamp (logtime: true, the default).
+const timeLayout = "2006/01/02 15:04:05.000000"
+
+// lineRE matches "[pid] 2006/01/02 15:04:05.000000 [INF] message"; pid and timestamp are optional.
+var lineRE = regexp.MustCompile(`^(?:\[(\d+)\] )?(?:(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}\.\d{6}) )?\[(TRC|DBG|INF|WRN|ERR|FTL)\] ?(.*)$`)
+
+// Event is one line of nats-server output, read from Stream ("stdout" or "stderr"). Lines that do not match the log format (e.g. a Go panic or goroutine
+// dump) have Parsed false, an empty Level and Message equal to Raw.
+type Event struct {
+	Time    time.Time
+	PID     int
+	Level   Level
+	Message string
+	Stream  string
+	Raw     string
+	Parsed  bool
+}
+
+// Parse parses one nats-server log line read from stream.
+func Parse(stream, line string) Event {
+	e := Event{Stream: stream, Raw: line, Message: line}
+	m := lineRE.FindStringSubmatch(line)
+	if m == nil {
+		return e
+	}
+	e.Parsed = true
+	if m[1] != "" {
+		e.PID, _ = strconv.Atoi(m[1])
+	}
+	if m[2] != "" {
+		if t, err := time.ParseInLocation(timeLayout, m[2], time.Local); err == nil {
+			e.Time = t
+		}
+	}
+	e.Level = Level(m[3])
+	e.Message = m[4]
+	return e
+}
+
+// Hub fans out child log events to subscribers. Subscribers are called synchronously from the reader goroutines,
+// in subscription order, and must not block.
+type Hub struct {
+	mu   sync.RWMutex
+	next int
+	subs []subscriber
+}
+
+type subscriber struct {
+	id int
+	fn func(Event)
+}
+
+// Subscribe registers fn for every subsequent event. The returned function removes the subscription.
+func (h *Hub) Subscribe(fn func(Event)) (unsubscribe func()) {
+	h.mu.Lock()
+	id := h.next
+	h.next++
+	h.subs = append(h.subs, subscriber{id, fn})
+	h.mu.Unlock()
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for i, s := range h.subs {
+			if s.id == id {
+				h.subs = append(h.subs[:i:i], h.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers e to all subscribers.
+func (h *Hub) Publish(e Event) {
+	h.mu.RLock()
+	subs := h.subs
+	h.mu.RUnlock()
+	for _, s := range subs {
+		s.fn(e)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package reload
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Deferral reasons.
+const (
+	DeferMinInterval = "min interval"
+	DeferWindow      = "maintenance window"
+	DeferInvalidTLS  = "invalid TLS material"
+)
+
+// DeferError is returned by a stage to postpone a run: the pipeline holds the run, which re-enters that stage at
+// Until unless a later run takes it along sooner.
+type DeferError struct {
+	Action Action
+	Until  time.Time
+	Reason string
+}
+
+func (e *DeferError) Error() string {
+	return fmt.Sprintf("%s deferred until %s (%s)", e.Action, e.Until.Format(time.RFC3339), e.Reason)
+}
+
+// Deferral is a run postponed by a DeferError, as reported by Pipeline.Deferred.
+type Deferral struct {
+	Causes []string  `json:"causes"`
+	Action Action    `json:"action"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// RestartClass reports whether a interrupts clients, so maintenance windows apply to it.
+func (a Action) RestartClass() bool {
+	return a == ActionRestart || a == ActionLameDuckRestart
+}
+
+// Limiter rate-limits actions and holds restart-class actions until a maintenance window opens.
+type Limiter struct {
+	// MinInterval is the minimum time between two actions; 0 disables it.
+	MinInterval time.Duration
+	// Windows are the maintenance windows for restart-class actions; none means any time.
+	Windows []Window
+	// Urgent causes are never deferred, e.g. certificate rotation. Neither is CauseManual.
+	Urgent []Cause
+	// Clock defaults to SystemClock.
+	Clock Clock
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (l *Limiter) now() time.Time {
+	if l.Clock == nil {
+		return SystemClock.Now()
+	}
+	return l.Clock.Now()
+}
+
+// exempt reports whether r goes through at once: it has an urgent cause or is a manual (admin) request.
+func (l *Limiter) exempt(r *Run) bool {
+	return r.HasAny(l.Urgent...) || r.Has(CauseManual)
+}
+
+// Check returns a *DeferError if the action of r may not run now. Run it after the action is decided. Runs with an
+// urgent cause and manual requests are neither rate-limited nor held for a window.
+func (l *Limiter) Check(r *Run) error {
+	if r.Action == ActionNone {
+		return nil
+	}
+	now := l.now()
+	l.mu.Lock()
+	last := l.last
+	l.mu.Unlock()
+	if l.exempt(r) {
+		return nil
+	}
+	if l.MinInterval > 0 && !last.IsZero() {
+		if until := last.Add(l.MinInterval); now.Before(until) {
+			return &DeferError{Action: r.Action, Until: until, Reason: DeferMinInterval}
+		}
+	}
+	if r.Action.RestartClass() && len(l.Windows) > 0 && !InWindow(l.Windows, now) {
+		until := NextWindow(l.Windows, now)
+		if until.IsZero() {
+			// A window that never opens would hold the action forever; check again in a day.
+			until = now.Add(24 * time.Hour)
+		}
+		return &DeferError{Action: r.Action, Until: until, Reason: DeferWindow}
+	}
+	return nil
+}
+
+// Done records that an action ran at t, starting the minimum interval.
+func (l *Limiter) Done(t time.Time) {
+	l.mu.Lock()
+	l.last = t
+	l.mu.Unlock()
+}
+
+// JoinHeld is the Stage.Joins of the limit stage. A run that restarts anyway takes held runs along; a reload joins
+// only runs that are not restarts, so it is not held for a restart's window; a run with no action for nats-server
+// (e.g. a sync-only JWT change) joins none.
+func JoinHeld(r, held *Run) bool {
+	return r.Action.RestartClass() || (r.Action != ActionNone && !held.Action.RestartClass())
+}
@@ -0,0 +1,263 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package reload
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/datasance/nats-server/internal/logging"
+)
+
+var logger = logging.Component("reload")
+
+// Stage is a step of a run. A stage that returns an error ends the run: later stages and post-run work are skipped.
+// Stages log and record their own failures and vetoes.
+type Stage struct {
+	Name string
+	Run  func(ctx context.Context, r *Run) error
+	// Joins reports whether run r, reaching the stage while held was deferred there, takes held along. Runs it
+	// rejects pass the stage on their own and held stays held. Nil takes every held run along.
+	Joins func(r, held *Run) bool
+}
+
+// Options configures a Pipeline.
+type Options struct {
+	// Debounce is the quiet time after the last trigger before a run starts.
+	Debounce time.Duration
+	// MaxWait bounds the time from the first trigger of a batch to its run, so continuous churn cannot postpone a
+	// run forever. 0 disables the bound.
+	MaxWait time.Duration
+	// Clock defaults to SystemClock.
+	Clock Clock
+	// Stages run in order for each run.
+	Stages []Stage
+}
+
+// Pipeline coalesces triggers into debounced runs through the stages. Runs are serialized. Post-run work (see
+// Run.Post) runs in the background and is cancelled when post-run work of a later run starts.
+//
+// A run deferred by a stage (see DeferError) is held and re-enters the pipeline at that stage at Until. Runs reaching
+// the stage in the meantime take the held runs the stage lets them join (see Stage.Joins) along: their causes and
+// files are merged, and the deferring stage checks the merged run again, so later changes cannot bypass a deferral.
+// Runs that do not join pass on their own, e.g. a certificate rotation while a config restart waits for a window.
+type Pipeline struct {
+	ctx  context.Context
+	opts Options
+
+	mu      sync.Mutex
+	pending *Run
+	files   map[string]struct{}
+	first   time.Time
+	timer   Timer
+	gen     uint64
+
+	// held are the deferred runs; guarded by mu and changed only under runMu.
+	held []*heldRun
+
+	runMu sync.Mutex
+
+	postMu     sync.Mutex
+	cancelPost context.CancelFunc
+}
+
+// New returns a pipeline whose runs and post-run work stop when ctx is done.
+func New(ctx context.Context, opts Options) *Pipeline {
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+	return &Pipeline{ctx: ctx, opts: opts}
+}
+
+// Trigger adds c and the changed files to the pending batch and restarts the debounce. With force, the run skips
+// unchanged-content checks.
+func (p *Pipeline) Trigger(c Cause, files []string, force bool) {
+	p.trigger(c, files, force, ActionNone)
+}
+
+// Request triggers a forced run with cause c that takes at least action a, e.g. an admin restart with CauseManual.
+func (p *Pipeline) Request(c Cause, a Action) {
+	p.trigger(c, nil, true, a)
+}
+
+func (p *Pipeline) trigger(c Cause, files []string, force bool, a Action) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.opts.Clock.Now()
+	if p.pending == nil {
+		p.pending = NewRun()
+		p.files = make(map[string]struct{})
+		p.first = now
+	}
+	p.pending.causes[c] = true
+	p.pending.Force = p.pending.Force || force
+	p.pending.Request = Stronger(p.pending.Request, a)
+	for _, f := range files {
+		p.files[f] = struct{}{}
+	}
+	delay := p.opts.Debounce
+	if p.opts.MaxWait > 0 {
+		if left := p.first.Add(p.opts.MaxWait).Sub(now); left < delay {
+			delay = max(left, 0)
+		}
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.gen++
+	gen := p.gen
+	p.timer = p.opts.Clock.AfterFunc(delay, func() { p.fire(gen) })
+}
+
+// Pending returns the causes waiting for the debounce and when the first of them arrived; nil if none.
+func (p *Pipeline) Pending() ([]Cause, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == nil {
+		return nil, time.Time{}
+	}
+	return p.pending.Active(), p.first
+}
+
+// fire runs the pending batch unless a later trigger rescheduled it.
+func (p *Pipeline) fire(gen uint64) {
+	p.mu.Lock()
+	if gen != p.gen || p.pending == nil {
+		p.mu.Unlock()
+		return
+	}
+	r := p.pending
+	for f := range p.files {
+		r.Files = append(r.Files, f)
+	}
+	p.pending, p.files, p.timer = nil, nil, nil
+	p.mu.Unlock()
+	sort.Strings(r.Files)
+	p.Run(r)
+}
+
+// Run passes r through the stages now, serialized with coalesced runs, and starts its post-run work if all stages
+// passed.
+func (p *Pipeline) Run(r *Run) {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+	p.run(r, 0)
+}
+
+// heldRun is a run deferred by stage, re-entering there when timer fires.
+type heldRun struct {
+	r     *Run
+	stage int
+	d     *DeferError
+	timer Timer
+}
+
+// run passes r through the stages from stage start on. The caller holds runMu.
+func (p *Pipeline) run(r *Run, start int) {
+	logger.Debug("Pipeline run", logging.KeyCause, r.Names(), "files", r.Files, "force", r.Force, "from", start)
+	for i := start; i < len(p.opts.Stages); i++ {
+		if p.ctx.Err() != nil {
+			return
+		}
+		s := p.opts.Stages[i]
+		p.takeHeld(r, i, s.Joins)
+		if err := s.Run(p.ctx, r); err != nil {
+			logger.Debug("Pipeline run ended", "stage", s.Name, logging.KeyCause, r.Names(), logging.Err(err))
+			var d *DeferError
+			if errors.As(err, &d) {
+				p.hold(r, i, d)
+			}
+			return
+		}
+	}
+	if r.Post != nil {
+		p.StartPost(r.Post)
+	}
+}
+
+// takeHeld merges the runs held at stage i that joins lets r join into r and releases them; r runs on in their place.
+func (p *Pipeline) takeHeld(r *Run, i int, joins func(r, held *Run) bool) {
+	p.mu.Lock()
+	var taken []*Run
+	kept := p.held[:0]
+	for _, h := range p.held {
+		if h.stage == i && (joins == nil || joins(r, h.r)) {
+			h.timer.Stop()
+			taken = append(taken, h.r)
+			continue
+		}
+		kept = append(kept, h)
+	}
+	p.held = kept
+	p.mu.Unlock()
+	for _, held := range taken {
+		r.Merge(held)
+	}
+}
+
+// hold keeps r, deferred by stage i, and schedules it to re-enter there at d.Until.
+func (p *Pipeline) hold(r *Run, i int, d *DeferError) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r.deferred = true
+	h := &heldRun{r: r, stage: i, d: d}
+	h.timer = p.opts.Clock.AfterFunc(max(d.Until.Sub(p.opts.Clock.Now()), 0), func() { p.resume(h) })
+	p.held = append(p.held, h)
+}
+
+// resume runs h from its deferring stage, unless it was taken along by another run since.
+func (p *Pipeline) resume(h *heldRun) {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+	p.mu.Lock()
+	i := slices.Index(p.held, h)
+	if i < 0 {
+		p.mu.Unlock()
+		return
+	}
+	p.held = slices.Delete(p.held, i, i+1)
+	p.mu.Unlock()
+	p.run(h.r, h.stage)
+}
+
+// Deferred returns the held runs, soonest first; nil if none.
+func (p *Pipeline) Deferred() []Deferral {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []Deferral
+	for _, h := range p.held {
+		out = append(out, Deferral{Causes: h.r.Names(), Action: h.d.Action, Until: h.d.Until, Reason: h.d.Reason})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Until.Before(out[j].Until) })
+	return out
+}
+
+// StartPost runs fn in the background, cancelling the post-run work started before it; later work repeats it in full
+// (e.g. reconcile and claims push), so finishing the superseded work is pointless.
+func (p *Pipeline) StartPost(fn func(ctx context.Context)) {
+	p.postMu.Lock()
+	if p.cancelPost != nil {
+		p.cancelPost()
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.cancelPost = cancel
+	p.postMu.Unlock()
+	go func() {
+		defer cancel()
+		fn(ctx)
+	}()
+}
@@ -0,0 +1,637 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package reload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/datasance/nats-server/internal/metrics"
+	"github.com/datasance/nats-server/internal/nats"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeClock runs AfterFunc calls synchronously from Advance, in the order they fall due.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: epoch}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	was := !t.stopped
+	t.stopped = true
+	return was
+}
+
+// Advance moves the clock forward by d, calling each timer that falls due at its due time.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		var next *fakeTimer
+		for _, t := range c.timers {
+			if !t.stopped && !t.at.After(end) && (next == nil || t.at.Before(next.at)) {
+				next = t
+			}
+		}
+		if next == nil {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		next.stopped = true
+		if next.at.After(c.now) {
+			c.now = next.at
+		}
+		c.mu.Unlock()
+		next.f()
+	}
+}
+
+// fakeServer records the actions ApplyStage takes.
+type fakeServer struct {
+	calls      []string
+	reloadErr  error
+	restartErr error
+	down       bool
+}
+
+func (s *fakeServer) Reload() error {
+	s.calls = append(s.calls, "reload")
+	return s.reloadErr
+}
+
+func (s *fakeServer) Restart(lameDuck bool) error {
+	if lameDuck {
+		s.calls = append(s.calls, "lame-duck-restart")
+	} else {
+		s.calls = append(s.calls, "restart")
+	}
+	return s.restartErr
+}
+
+func (s *fakeServer) Running() bool {
+	return !s.down
+}
+
+// record is a run as the last stage saw it.
+type record struct {
+	At     time.Duration
+	Causes []string
+	Files  []string
+	Force  bool
+}
+
+// recorder is a stage that records every run reaching it.
+type recorder struct {
+	clock *fakeClock
+	runs  []record
+}
+
+func (rec *recorder) stage() Stage {
+	return Stage{Name: "record", Run: func(_ context.Context, r *Run) error {
+		rec.runs = append(rec.runs, record{At: rec.clock.Now().Sub(epoch), Causes: r.Names(), Files: r.Files, Force: r.Force})
+		return nil
+	}}
+}
+
+func TestPipelineCoalescing(t *testing.T) {
+	type trigger struct {
+		At    time.Duration
+		Cause Cause
+		Files []string
+		Force bool
+	}
+	tests := []struct {
+		name     string
+		debounce time.Duration
+		maxWait  time.Duration
+		triggers []trigger
+		until    time.Duration
+		want     []record
+	}{
+		{
+			name:     "debounce runs once after quiet time",
+			debounce: time.Second,
+			triggers: []trigger{{At: 0, Cause: CauseConfig}, {At: 500 * time.Millisecond, Cause: CauseConfig}},
+			until:    10 * time.Second,
+			want:     []record{{At: 1500 * time.Millisecond, Causes: []string{"config"}}},
+		},
+		{
+			name:     "separate bursts run separately",
+			debounce: time.Second,
+			triggers: []trigger{{At: 0, Cause: CauseConfig}, {At: 5 * time.Second, Cause: CauseSSL}},
+			until:    10 * time.Second,
+			want: []record{
+				{At: time.Second, Causes: []string{"config"}},
+				{At: 6 * time.Second, Causes: []string{"ssl"}},
+			},
+		},
+		{
+			name:     "max wait bounds continuous churn",
+			debounce: time.Second,
+			maxWait:  2500 * time.Millisecond,
+			triggers: []trigger{
+				{At: 0, Cause: CauseJWT}, {At: 800 * time.Millisecond, Cause: CauseJWT},
+				{At: 1600 * time.Millisecond, Cause: CauseJWT}, {At: 2400 * time.Millisecond, Cause: CauseJWT},
+			},
+			until: 10 * time.Second,
+			want:  []record{{At: 2500 * time.Millisecond, Causes: []string{"jwt"}}},
+		},
+		{
+			name:     "without max wait churn postpones the run",
+			debounce: time.Second,
+			triggers: []trigger{
+				{At: 0, Cause: CauseJWT}, {At: 800 * time.Millisecond, Cause: CauseJWT},
+				{At: 1600 * time.Millisecond, Cause: CauseJWT}, {At: 2400 * time.Millisecond, Cause: CauseJWT},
+			},
+			until: 10 * time.Second,
+			want:  []record{{At: 3400 * time.Millisecond, Causes: []string{"jwt"}}},
+		},
+		{
+			name:     "causes, files and force coalesce",
+			debounce: time.Second,
+			triggers: []trigger{
+				{At: 0, Cause: CauseSSL, Files: []string{"/ssl/tls.key"}},
+				{At: 100 * time.Millisecond, Cause: CauseConfig, Files: []string{"/conf/nats.conf"}, Force: true},
+				{At: 200 * time.Millisecond, Cause: CauseSSL, Files: []string{"/ssl/tls.crt", "/ssl/tls.key"}},
+			},
+			until: 10 * time.Second,
+			want: []record{{
+				At:     1200 * time.Millisecond,
+				Causes: []string{"config", "ssl"},
+				Files:  []string{"/conf/nats.conf", "/ssl/tls.crt", "/ssl/tls.key"},
+				Force:  true,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			rec := &recorder{clock: clock}
+			p := New(context.Background(), Options{Debounce: tt.debounce, MaxWait: tt.maxWait, Clock: clock, Stages: []Stage{rec.stage()}})
+			var at time.Duration
+			for _, tr := range tt.triggers {
+				clock.Advance(tr.At - at)
+				at = tr.At
+				p.Trigger(tr.Cause, tr.Files, tr.Force)
+			}
+			clock.Advance(tt.until - at)
+			if !reflect.DeepEqual(rec.runs, tt.want) {
+				t.Errorf("runs = %+v, want %+v", rec.runs, tt.want)
+			}
+			if causes, _ := p.Pending(); causes != nil {
+				t.Errorf("pending = %v after the runs, want none", causes)
+			}
+		})
+	}
+}
+
+func TestPipelineDeferral(t *testing.T) {
+	type trigger struct {
+		At    time.Duration
+		Cause Cause
+		Files []string
+	}
+	tests := []struct {
+		name string
+		// holdUntil is the time the limit stage defers restarts to for each call, in order; past the list they pass.
+		holdUntil []time.Duration
+		triggers  []trigger
+		// check is when Deferred is checked.
+		check       time.Duration
+		wantHeld    []Deferral
+		want        []record
+		wantDecided int
+		wantCounted int
+	}{
+		{
+			name:        "re-enters at the deferring stage",
+			holdUntil:   []time.Duration{11 * time.Second},
+			triggers:    []trigger{{At: 0, Cause: CauseConfig, Files: []string{"/conf/nats.conf"}}},
+			check:       10 * time.Second,
+			wantHeld:    []Deferral{{Causes: []string{"config"}, Action: ActionRestart, Until: epoch.Add(11 * time.Second), Reason: DeferWindow}},
+			want:        []record{{At: 11 * time.Second, Causes: []string{"config"}, Files: []string{"/conf/nats.conf"}}},
+			wantDecided: 1,
+			wantCounted: 1,
+		},
+		{
+			name:      "later restarts merge into the held run",
+			holdUntil: []time.Duration{20 * time.Second, 20 * time.Second},
+			triggers: []trigger{
+				{At: 0, Cause: CauseConfig, Files: []string{"/conf/nats.conf"}},
+				{At: 5 * time.Second, Cause: CauseAccounts, Files: []string{"/conf/accounts.conf"}},
+			},
+			check:       10 * time.Second,
+			wantHeld:    []Deferral{{Causes: []string{"accounts", "config"}, Action: ActionRestart, Until: epoch.Add(20 * time.Second), Reason: DeferWindow}},
+			want:        []record{{At: 20 * time.Second, Causes: []string{"accounts", "config"}, Files: []string{"/conf/accounts.conf", "/conf/nats.conf"}}},
+			wantDecided: 2,
+			wantCounted: 1,
+		},
+		{
+			name:      "held again counts once",
+			holdUntil: []time.Duration{5 * time.Second, 10 * time.Second},
+			triggers:  []trigger{{At: 0, Cause: CauseConfig}},
+			check:     7 * time.Second,
+			wantHeld:  []Deferral{{Causes: []string{"config"}, Action: ActionRestart, Until: epoch.Add(10 * time.Second), Reason: DeferWindow}},
+			want:      []record{{At: 10 * time.Second, Causes: []string{"config"}}},
+			// The deferral is re-checked at the limit stage only.
+			wantDecided: 1,
+			wantCounted: 1,
+		},
+		{
+			name:      "sync-only trigger passes the held run",
+			holdUntil: []time.Duration{time.Hour},
+			triggers: []trigger{
+				{At: 0, Cause: CauseConfig},
+				{At: 5 * time.Second, Cause: CauseJWT, Files: []string{"/jwt/A.jwt"}},
+			},
+			check:    10 * time.Second,
+			wantHeld: []Deferral{{Causes: []string{"config"}, Action: ActionRestart, Until: epoch.Add(time.Hour), Reason: DeferWindow}},
+			want: []record{
+				{At: 6 * time.Second, Causes: []string{"jwt"}, Files: []string{"/jwt/A.jwt"}},
+				{At: time.Hour, Causes: []string{"config"}},
+			},
+			wantDecided: 2,
+			wantCounted: 1,
+		},
+		{
+			name:      "urgent reload passes the held run",
+			holdUntil: []time.Duration{time.Hour},
+			triggers: []trigger{
+				{At: 0, Cause: CauseConfig},
+				{At: 5 * time.Second, Cause: CauseSSL},
+			},
+			check:    10 * time.Second,
+			wantHeld: []Deferral{{Causes: []string{"config"}, Action: ActionRestart, Until: epoch.Add(time.Hour), Reason: DeferWindow}},
+			want: []record{
+				{At: 6 * time.Second, Causes: []string{"ssl"}},
+				{At: time.Hour, Causes: []string{"config"}},
+			},
+			wantDecided: 2,
+			wantCounted: 1,
+		},
+		{
+			name:      "reloads are held apart from restarts",
+			holdUntil: []time.Duration{time.Hour},
+			triggers: []trigger{
+				{At: 0, Cause: CauseConfig},
+				{At: 5 * time.Second, Cause: CauseCreds},
+			},
+			check: 10 * time.Second,
+			wantHeld: []Deferral{
+				{Causes: []string{"creds"}, Action: ActionReload, Until: epoch.Add(30 * time.Second), Reason: DeferMinInterval},
+				{Causes: []string{"config"}, Action: ActionRestart, Until: epoch.Add(time.Hour), Reason: DeferWindow},
+			},
+			want: []record{
+				{At: 30 * time.Second, Causes: []string{"creds"}},
+				{At: time.Hour, Causes: []string{"config"}},
+			},
+			wantDecided: 2,
+			wantCounted: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			rec := &recorder{clock: clock}
+			holds := tt.holdUntil
+			decided, counted := 0, 0
+			p := New(context.Background(), Options{Debounce: time.Second, Clock: clock, Stages: []Stage{
+				// As in leaf mode: config and accounts restart, ssl (urgent) and creds reload, jwt is sync-only.
+				{Name: "decide", Run: func(_ context.Context, r *Run) error {
+					decided++
+					switch {
+					case r.HasAny(CauseConfig, CauseAccounts):
+						r.Action = ActionRestart
+					case r.HasAny(CauseSSL, CauseCreds):
+						r.Action = ActionReload
+					default:
+						r.Action = ActionNone
+					}
+					return nil
+				}},
+				// Restarts wait for holdUntil; creds reloads for a minimum interval until 30s.
+				{Name: "limit", Joins: JoinHeld, Run: func(_ context.Context, r *Run) error {
+					var d *DeferError
+					switch {
+					case r.Action.RestartClass() && len(holds) > 0:
+						d = &DeferError{Action: r.Action, Until: epoch.Add(holds[0]), Reason: DeferWindow}
+						holds = holds[1:]
+					case r.Has(CauseCreds) && clock.Now().Before(epoch.Add(30*time.Second)):
+						d = &DeferError{Action: r.Action, Until: epoch.Add(30 * time.Second), Reason: DeferMinInterval}
+					default:
+						return nil
+					}
+					if !r.Deferred() {
+						counted++
+					}
+					return d
+				}},
+				rec.stage(),
+			}})
+			var at time.Duration
+			step := func(to time.Duration) {
+				clock.Advance(to - at)
+				at = to
+			}
+			checked := false
+			for _, tr := range tt.triggers {
+				if !checked && tt.check <= tr.At {
+					step(tt.check)
+					checked = true
+					if d := p.Deferred(); !reflect.DeepEqual(d, tt.wantHeld) {
+						t.Errorf("Deferred() = %+v, want %+v", d, tt.wantHeld)
+					}
+				}
+				step(tr.At)
+				p.Trigger(tr.Cause, tr.Files, false)
+			}
+			if !checked {
+				step(tt.check)
+				if d := p.Deferred(); !reflect.DeepEqual(d, tt.wantHeld) {
+					t.Errorf("Deferred() = %+v, want %+v", d, tt.wantHeld)
+				}
+			}
+			step(2 * time.Hour)
+			if !reflect.DeepEqual(rec.runs, tt.want) {
+				t.Errorf("runs = %+v, want %+v", rec.runs, tt.want)
+			}
+			if decided != tt.wantDecided {
+				t.Errorf("decide ran %d times, want %d", decided, tt.wantDecided)
+			}
+			if counted != tt.wantCounted {
+				t.Errorf("deferral counted %d times, want %d", counted, tt.wantCounted)
+			}
+			if d := p.Deferred(); d != nil {
+				t.Errorf("Deferred() = %+v after the run, want nil", d)
+			}
+		})
+	}
+}
+
+func TestPipelineStageError(t *testing.T) {
+	clock := newFakeClock()
+	rec := &recorder{clock: clock}
+	p := New(context.Background(), Options{Clock: clock, Stages: []Stage{
+		{Name: "veto", Run: func(context.Context, *Run) error { return errors.New("vetoed") }},
+		rec.stage(),
+	}})
+	p.Trigger(CauseSSL, nil, false)
+	clock.Advance(time.Minute)
+	if len(rec.runs) != 0 {
+		t.Errorf("runs = %+v, want none after a failed stage", rec.runs)
+	}
+	if d := p.Deferred(); d != nil {
+		t.Errorf("Deferred() = %+v, want nil: failures are not requeued", d)
+	}
+}
+
+func TestApplyStage(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      Action
+		server      fakeServer
+		wantCalls   []string
+		wantOutcome string
+		wantErr     bool
+		restarted   bool
+	}{
+		{name: "reload", action: ActionReload, wantCalls: []string{"reload"}, wantOutcome: metrics.OutcomeSuccess},
+		{
+			name: "reload rejected", action: ActionReload, server: fakeServer{reloadErr: &nats.ReloadRejectedError{Message: "bad config"}},
+			wantCalls: []string{"reload"}, wantOutcome: metrics.OutcomeRejected, wantErr: true,
+		},
+		{
+			name: "reload unconfirmed", action: ActionReload, server: fakeServer{reloadErr: nats.ErrReloadUnconfirmed},
+			wantCalls: []string{"reload"}, wantOutcome: metrics.OutcomeUnconfirmed,
+		},
+		{
+			name: "reload failed", action: ActionReload, server: fakeServer{reloadErr: errors.New("signal failed")},
+			wantCalls: []string{"reload"}, wantOutcome: metrics.OutcomeFailure, wantErr: true,
+		},
+		{name: "reload while down", action: ActionReload, server: fakeServer{down: true}, wantOutcome: metrics.OutcomeFailure, wantErr: true},
+		{name: "restart", action: ActionRestart, wantCalls: []string{"restart"}, wantOutcome: metrics.OutcomeRestart, restarted: true},
+		{
+			name: "lame duck restart", action: ActionLameDuckRestart,
+			wantCalls: []string{"lame-duck-restart"}, wantOutcome: metrics.OutcomeRestart, restarted: true,
+		},
+		{
+			name: "restart failed", action: ActionRestart, server: fakeServer{restartErr: errors.New("stop failed")},
+			wantCalls: []string{"restart"}, wantOutcome: metrics.OutcomeFailure, wantErr: true, restarted: true,
+		},
+		{name: "none", action: ActionNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			s := tt.server
+			r := NewRun(CauseConfig)
+			r.Action = tt.action
+			if err := ApplyStage(&s, clock).Run(context.Background(), r); err != nil {
+				t.Fatalf("ApplyStage: %v", err)
+			}
+			if !reflect.DeepEqual(s.calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", s.calls, tt.wantCalls)
+			}
+			if r.Outcome != tt.wantOutcome {
+				t.Errorf("Outcome = %q, want %q", r.Outcome, tt.wantOutcome)
+			}
+			if (r.Err != nil) != tt.wantErr {
+				t.Errorf("Err = %v, want error %v", r.Err, tt.wantErr)
+			}
+			if got := !r.RestartedAt.IsZero(); got != tt.restarted {
+				t.Errorf("RestartedAt = %v, want set %v", r.RestartedAt, tt.restarted)
+			}
+		})
+	}
+}
+
+func TestContentAndDecide(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		cause      Cause
+		rewrite    string
+		force      bool
+		wantCauses []string
+		wantAction Action
+	}{
+		{name: "unchanged config dropped", mode: "server", cause: CauseConfig, rewrite: "port: 4222\n"},
+		{name: "changed config reloads", mode: "server", cause: CauseConfig, rewrite: "port: 4223\n", wantCauses: []string{"config"}, wantAction: ActionReload},
+		{name: "forced unchanged config reloads", mode: "server", cause: CauseConfig, rewrite: "port: 4222\n", force: true, wantCauses: []string{"config"}, wantAction: ActionReload},
+		{name: "leaf restarts on config", mode: "leaf", cause: CauseConfig, rewrite: "port: 4223\n", wantCauses: []string{"config"}, wantAction: ActionRestart},
+		{name: "leaf syncs jwt only", mode: "leaf", cause: CauseJWT, rewrite: "port: 4222\n", wantCauses: []string{"jwt"}, wantAction: ActionNone},
+		{name: "cluster lame duck on config", mode: "cluster", cause: CauseConfig, rewrite: "port: 4223\n", wantCauses: []string{"config"}, wantAction: ActionLameDuckRestart},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "nats.conf")
+			if err := os.WriteFile(path, []byte("port: 4222\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			hash := NewConfigHash(path)
+			if err := os.WriteFile(path, []byte(tt.rewrite), 0644); err != nil {
+				t.Fatal(err)
+			}
+			policy, err := ParsePolicy(tt.mode, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			synced := false
+			r := NewRun(tt.cause)
+			r.Force = tt.force
+			for _, s := range []Stage{ContentStage(hash), DecideStage(policy), SyncStage(func(context.Context) { synced = true })} {
+				if err := s.Run(context.Background(), r); err != nil {
+					t.Fatalf("%s: %v", s.Name, err)
+				}
+			}
+			got := r.Names()
+			sort.Strings(got)
+			if len(got) == 0 {
+				got = nil
+			}
+			if !reflect.DeepEqual(got, tt.wantCauses) {
+				t.Errorf("causes = %v, want %v", got, tt.wantCauses)
+			}
+			if r.Action != tt.wantAction {
+				t.Errorf("Action = %q, want %q", r.Action, tt.wantAction)
+			}
+			if want := r.Has(CauseJWT); synced != want {
+				t.Errorf("synced = %v, want %v", synced, want)
+			}
+		})
+	}
+}
+
+func TestConfigHashLoaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nats.conf")
+	if err := os.WriteFile(path, []byte("port: 4222\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	published := func() string {
+		var b strings.Builder
+		if _, err := metrics.Default.WriteTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(b.String(), "\n") {
+			if strings.HasPrefix(line, "pot_nats_config_info{") {
+				return line
+			}
+		}
+		return ""
+	}
+	hash := NewConfigHash(path)
+	loaded := published()
+	if loaded == "" {
+		t.Fatal("no pot_nats_config_info sample at startup")
+	}
+
+	if err := os.WriteFile(path, []byte("port: 4223\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRun(CauseConfig)
+	if err := ContentStage(hash).Run(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Has(CauseConfig) {
+		t.Fatal("changed config dropped")
+	}
+	if got := published(); got != loaded {
+		t.Errorf("config info = %s after the content stage, want unchanged %s", got, loaded)
+	}
+	hash.Loaded()
+	if got := published(); got == loaded {
+		t.Errorf("config info = %s after Loaded, want the new hash", got)
+	}
+}
+
+func TestPipelineRequest(t *testing.T) {
+	clock := newFakeClock()
+	policy, err := ParsePolicy("server", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows, err := ParseWindows("0 2 * * * 1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := &Limiter{Windows: windows, Clock: clock}
+	s := &fakeServer{}
+	var runs []*Run
+	p := New(context.Background(), Options{Debounce: time.Second, Clock: clock, Stages: []Stage{
+		DecideStage(policy),
+		{Name: "limit", Run: func(_ context.Context, r *Run) error { return limiter.Check(r) }},
+		ApplyStage(s, clock),
+		{Name: "record", Run: func(_ context.Context, r *Run) error {
+			runs = append(runs, r)
+			return nil
+		}},
+	}})
+	p.Trigger(CauseAccounts, nil, false)
+	p.Request(CauseManual, ActionRestart)
+	clock.Advance(time.Minute)
+
+	if len(runs) != 1 {
+		t.Fatalf("%d runs, want 1", len(runs))
+	}
+	r := runs[0]
+	if want := []string{"accounts", "manual"}; !reflect.DeepEqual(r.Names(), want) {
+		t.Errorf("causes = %v, want %v", r.Names(), want)
+	}
+	if r.Action != ActionRestart || !r.Force {
+		t.Errorf("Action = %q, Force = %v; want restart, forced", r.Action, r.Force)
+	}
+	if want := []string{"restart"}; !reflect.DeepEqual(s.calls, want) {
+		t.Errorf("calls = %v, want %v (a manual restart is not held for a window)", s.calls, want)
+	}
+}
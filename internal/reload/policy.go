@@ -0,0 +1,142 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package reload
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Policy actions, in increasing strength. When a run has several causes, the strongest action wins.
+const (
+	// ActionIgnore drops the cause: nothing runs for it, not even the JWT sync.
+	ActionIgnore Action = "ignore"
+	// ActionSyncOnly runs the sync and post-run work (JWT sync, reconcile, claims push) but leaves nats-server alone.
+	ActionSyncOnly Action = "sync-only"
+	// ActionLameDuckRestart puts nats-server into lame duck mode, so clients move to other servers, and starts it
+	// again once it has exited.
+	ActionLameDuckRestart Action = "lame-duck-restart"
+)
+
+// strength orders policy actions; ActionReload and ActionRestart are defined in reload.go.
+var strength = map[Action]int{
+	ActionIgnore:          0,
+	ActionSyncOnly:        1,
+	ActionReload:          2,
+	ActionRestart:         3,
+	ActionLameDuckRestart: 4,
+}
+
+// Stronger returns the stronger of a and b; ActionNone is the weakest.
+func Stronger(a, b Action) Action {
+	if strength[b] > strength[a] || a == ActionNone {
+		return b
+	}
+	return a
+}
+
+// Policy maps each cause to the action taking effect when it changes. Causes not in the policy are ignored.
+type Policy map[Cause]Action
+
+// Presets are the built-in policies, selected by NATS_SERVER_MODE.
+var Presets = map[string]Policy{
+	// server: nats-server reloads every input.
+	"server": {CauseConfig: ActionReload, CauseAccounts: ActionReload, CauseSSL: ActionReload, CauseJWT: ActionReload, CauseCreds: ActionReload},
+	// hub: accepts leafnode connections; reloads like server.
+	"hub": {CauseConfig: ActionReload, CauseAccounts: ActionReload, CauseSSL: ActionReload, CauseJWT: ActionReload, CauseCreds: ActionReload},
+	// cluster: route and cluster changes are not all reloadable; restart in lame duck mode so clients move to peers.
+	"cluster": {CauseConfig: ActionLameDuckRestart, CauseAccounts: ActionReload, CauseSSL: ActionReload, CauseJWT: ActionReload, CauseCreds: ActionReload},
+	// gateway: gateway changes are not reloadable; restart in lame duck mode.
+	"gateway": {CauseConfig: ActionLameDuckRestart, CauseAccounts: ActionReload, CauseSSL: ActionReload, CauseJWT: ActionReload, CauseCreds: ActionReload},
+	// leaf: leafnode remotes are not reloadable; restart on config, accounts and creds, reload certificates, and
+	// apply JWTs through the full resolver (claims push) without a reload.
+	"leaf": {CauseConfig: ActionRestart, CauseAccounts: ActionRestart, CauseSSL: ActionReload, CauseJWT: ActionSyncOnly, CauseCreds: ActionRestart},
+}
+
+// Modes returns the names of the presets, sorted.
+func Modes() []string {
+	out := make([]string, 0, len(Presets))
+	for m := range Presets {
+		out = append(out, m)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ParsePolicy returns the preset of mode with the overrides in spec applied. spec is a comma-separated list of
+// cause=action, e.g. "config=lame-duck-restart,creds=ignore"; it may be empty.
+func ParsePolicy(mode, spec string) (Policy, error) {
+	preset, ok := Presets[mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown server mode %q (want one of %v)", mode, Modes())
+	}
+	p := make(Policy, len(preset))
+	for c, a := range preset {
+		p[c] = a
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("policy entry %q: want cause=action", entry)
+		}
+		c, err := ParseCause(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("policy entry %q: %w", entry, err)
+		}
+		a := Action(strings.ToLower(strings.TrimSpace(value)))
+		if _, ok := strength[a]; !ok {
+			return nil, fmt.Errorf("policy entry %q: unknown action %q (want ignore, sync-only, reload, restart or lame-duck-restart)", entry, value)
+		}
+		p[c] = a
+	}
+	return p, nil
+}
+
+// Decide drops the causes of r the policy ignores and returns the strongest action of the remaining ones and of
+// r.Request: ActionNone if there is none or it is ActionSyncOnly.
+func (p Policy) Decide(r *Run) Action {
+	best := Stronger(ActionIgnore, r.Request)
+	for _, c := range r.Active() {
+		if c == CauseManual {
+			continue
+		}
+		a, ok := p[c]
+		if !ok || a == ActionIgnore {
+			r.Drop(c)
+			continue
+		}
+		if strength[a] > strength[best] {
+			best = a
+		}
+	}
+	if best == ActionIgnore || best == ActionSyncOnly {
+		return ActionNone
+	}
+	return best
+}
+
+// String formats p as cause=action pairs in cause order.
+func (p Policy) String() string {
+	var parts []string
+	for _, c := range Causes {
+		if a, ok := p[c]; ok {
+			parts = append(parts, string(c)+"="+string(a))
+		}
+	}
+	return strings.Join(parts, ",")
+}
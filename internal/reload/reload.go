@@ -0,0 +1,163 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Cause is an input of nats-server whose change triggers a pipeline run.
+type Cause string
+
+// Causes reported by the watchers.
+const (
+	CauseConfig   Cause = "config"
+	CauseAccounts Cause = "accounts"
+	CauseSSL      Cause = "ssl"
+	CauseJWT      Cause = "jwt"
+	CauseCreds    Cause = "creds"
+)
+
+// CauseManual is an operator request, e.g. an admin restart. It is not in Causes: the reload policy does not apply to
+// it; the run takes the requested action (see Run.Request).
+const CauseManual Cause = "manual"
+
+// Causes lists all causes.
+var Causes = []Cause{CauseConfig, CauseAccounts, CauseSSL, CauseJWT, CauseCreds}
+
+// ErrUnknownCause is returned by ParseCause for a name not in Causes.
+var ErrUnknownCause = errors.New("unknown reload cause")
+
+// ParseCause returns the cause named s.
+func ParseCause(s string) (Cause, error) {
+	for _, c := range Causes {
+		if string(c) == s {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q (want one of %v)", ErrUnknownCause, s, Causes)
+}
+
+// Action is what a run does to nats-server.
+type Action string
+
+// Actions. ActionNone leaves the server alone (e.g. nothing relevant changed, or the change is not loaded in this mode).
+const (
+	ActionNone    Action = ""
+	ActionReload  Action = "reload"
+	ActionRestart Action = "restart"
+)
+
+// Run is one pipeline run over the causes coalesced since the previous run. Stages read and update it in order.
+type Run struct {
+	// Files changed, as reported by the watchers, sorted.
+	Files []string
+	// Force is set when any trigger was forced (e.g. an admin request); stages skip unchanged-content checks.
+	Force bool
+	// Request is the action an operator asked for (see Pipeline.Request); the policy cannot weaken it.
+	Request Action
+	// Action decided by a stage; ActionNone until then.
+	Action Action
+	// Outcome and Err of the action, set by the stage applying it.
+	Outcome string
+	Err     error
+	// RestartedAt is the time a restart was requested; zero for a reload. Post-run work uses it to wait for the new process.
+	RestartedAt time.Time
+	// Post, if set by a stage, runs in the background after all stages passed; see Pipeline.StartPost.
+	Post func(ctx context.Context)
+
+	causes   map[Cause]bool
+	deferred bool
+}
+
+// NewRun returns a run for causes, e.g. for a resync outside the coalescer.
+func NewRun(causes ...Cause) *Run {
+	r := &Run{causes: make(map[Cause]bool, len(causes))}
+	for _, c := range causes {
+		r.causes[c] = true
+	}
+	return r
+}
+
+// Has reports whether c is an active cause of the run.
+func (r *Run) Has(c Cause) bool {
+	return r.causes[c]
+}
+
+// HasAny reports whether any of cs is an active cause of the run.
+func (r *Run) HasAny(cs ...Cause) bool {
+	for _, c := range cs {
+		if r.causes[c] {
+			return true
+		}
+	}
+	return false
+}
+
+// Drop deactivates c, e.g. when the content behind it did not actually change.
+func (r *Run) Drop(c Cause) {
+	if _, ok := r.causes[c]; ok {
+		r.causes[c] = false
+	}
+}
+
+// Deferred reports whether the run, or a run merged into it, was deferred before; stages record a deferral only once.
+func (r *Run) Deferred() bool {
+	return r.deferred
+}
+
+// Merge adds the active causes and the files of o to r. r is forced if either is, and takes the stronger action.
+func (r *Run) Merge(o *Run) {
+	for _, c := range o.Active() {
+		r.causes[c] = true
+	}
+	files := make(map[string]struct{}, len(r.Files)+len(o.Files))
+	for _, f := range append(r.Files, o.Files...) {
+		files[f] = struct{}{}
+	}
+	r.Files = r.Files[:0]
+	for f := range files {
+		r.Files = append(r.Files, f)
+	}
+	sort.Strings(r.Files)
+	r.Force = r.Force || o.Force
+	r.Request = Stronger(r.Request, o.Request)
+	r.Action = Stronger(r.Action, o.Action)
+	r.deferred = r.deferred || o.deferred
+}
+
+// Active returns the active causes, sorted.
+func (r *Run) Active() []Cause {
+	var out []Cause
+	for c, active := range r.causes {
+		if active {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Names returns the active causes as strings, sorted, for logs, metrics and events.
+func (r *Run) Names() []string {
+	active := r.Active()
+	out := make([]string, len(active))
+	for i, c := range active {
+		out[i] = string(c)
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package reload
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
+	"github.com/datasance/nats-server/internal/nats"
+)
+
+// ErrNotRunning is the error of a reload due while nats-server is not running.
+var ErrNotRunning = errors.New("nats-server not running")
+
+// Server is the nats-server process ApplyStage acts on.
+type Server interface {
+	// Reload makes the running server load its inputs again. It returns nats.ErrReloadUnconfirmed when the result
+	// could not be seen and a *nats.ReloadRejectedError when the server kept its previous configuration.
+	Reload() error
+	// Restart stops the server so that it is started again; with lameDuck, clients are moved away first.
+	Restart(lameDuck bool) error
+	// Running reports whether the server process is up.
+	Running() bool
+}
+
+// ConfigHash tracks the content of the server config, so a run for a config that did not actually change (e.g. a
+// ConfigMap rendered again with the same content) can be dropped. The hash of the config nats-server loaded is
+// published as pot_nats_config_info, see Loaded.
+type ConfigHash struct {
+	Path string
+
+	mu   sync.Mutex
+	seen [32]byte
+}
+
+// NewConfigHash returns a ConfigHash for the server config at path, seeded with its current content.
+func NewConfigHash(path string) *ConfigHash {
+	h := &ConfigHash{Path: path}
+	if sum, err := h.sum(); err == nil {
+		h.seen = sum
+		metrics.SetConfigHash(path, sum)
+	}
+	return h
+}
+
+func (h *ConfigHash) sum() ([32]byte, error) {
+	data, err := os.ReadFile(h.Path)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// Forget clears the hash, so the next run treats the config as changed (e.g. after a reload was vetoed).
+func (h *ConfigHash) Forget() {
+	h.mu.Lock()
+	h.seen = [32]byte{}
+	h.mu.Unlock()
+}
+
+// ContentStage drops the config cause of unforced runs whose server config content h has already seen. An unreadable
+// config counts as changed, so the run still reacts.
+func ContentStage(h *ConfigHash) Stage {
+	return Stage{Name: "content", Run: func(_ context.Context, r *Run) error {
+		if !r.Has(CauseConfig) || r.Force {
+			return nil
+		}
+		sum, err := h.sum()
+		if err != nil {
+			return nil
+		}
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if sum == h.seen {
+			r.Drop(CauseConfig)
+			metrics.Reloads.Inc(string(CauseConfig), metrics.OutcomeUnchanged)
+			return nil
+		}
+		h.seen = sum
+		return nil
+	}}
+}
+
+// Loaded publishes the content h has seen last as the loaded config. Call it once nats-server reloaded or restarted
+// with it, not when the change is seen: a rejected or deferred config is not loaded.
+func (h *ConfigHash) Loaded() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.seen != ([32]byte{}) {
+		metrics.SetConfigHash(h.Path, h.seen)
+	}
+}
+
+// DecideStage drops the causes p ignores and sets the action of the run.
+func DecideStage(p Policy) Stage {
+	return Stage{Name: "decide", Run: func(_ context.Context, r *Run) error {
+		r.Action = p.Decide(r)
+		return nil
+	}}
+}
+
+// SyncStage calls sync when jwt is still a cause after the policy, e.g. to sync the JWT mount dir to the resolver dir.
+func SyncStage(sync func(ctx context.Context)) Stage {
+	return Stage{Name: "sync", Run: func(ctx context.Context, r *Run) error {
+		if r.Has(CauseJWT) {
+			sync(ctx)
+		}
+		return nil
+	}}
+}
+
+// ApplyStage reloads or restarts s as r.Action says and sets the Outcome, Err and RestartedAt of the run. A failed
+// action does not end the run, so later stages can report it.
+func ApplyStage(s Server, clock Clock) Stage {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return Stage{Name: "apply", Run: func(_ context.Context, r *Run) error {
+		switch r.Action {
+		case ActionReload:
+			r.Outcome = metrics.OutcomeSuccess
+			if !s.Running() {
+				r.Err = ErrNotRunning
+			} else {
+				r.Err = s.Reload()
+			}
+			switch {
+			case errors.Is(r.Err, nats.ErrReloadUnconfirmed):
+				// SIGHUP was sent; the server just did not log the result (e.g. log_file set). Not a failure.
+				logger.Warn("Reload after change not confirmed", logging.KeyCause, r.Names(), logging.Err(r.Err))
+				r.Outcome = metrics.OutcomeUnconfirmed
+				r.Err = nil
+			case errors.As(r.Err, new(*nats.ReloadRejectedError)):
+				logger.Error("Reload after change rejected by nats-server", logging.KeyCause, r.Names(), logging.Err(r.Err))
+				r.Outcome = metrics.OutcomeRejected
+			case r.Err != nil:
+				logger.Error("Reload after change failed", logging.KeyCause, r.Names(), logging.Err(r.Err))
+				r.Outcome = metrics.OutcomeFailure
+			}
+		case ActionRestart, ActionLameDuckRestart:
+			r.RestartedAt = clock.Now()
+			r.Outcome = metrics.OutcomeRestart
+			r.Err = s.Restart(r.Action == ActionLameDuckRestart)
+			if r.Err != nil {
+				logger.Error("Stop for restart after change failed", logging.KeyCause, r.Names(), logging.Err(r.Err))
+				r.Outcome = metrics.OutcomeFailure
+			}
+		}
+		return nil
+	}}
+}
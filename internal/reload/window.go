@@ -0,0 +1,183 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package reload
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a recurring maintenance window: it opens at the times matched by a cron expression and stays open for
+// Duration.
+type Window struct {
+	Spec     string
+	Duration time.Duration
+
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// ParseWindows parses semicolon-separated windows, each a 5-field cron expression (minute hour day-of-month month
+// day-of-week; "*", numbers, ranges "a-b", lists "a,b" and steps "/n") followed by a duration, e.g.
+// "0 2 * * * 2h; 0 22 * * 6 10h". Windows are evaluated in the local time zone (TZ).
+func ParseWindows(spec string) ([]Window, error) {
+	var out []Window
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		w, err := parseWindow(entry)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %q: %w", entry, err)
+		}
+		out = append(out, w)
+	}
+	return out, nil
+}
+
+func parseWindow(entry string) (Window, error) {
+	fields := strings.Fields(entry)
+	if len(fields) != 6 {
+		return Window{}, fmt.Errorf("want 5 cron fields and a duration, got %d fields", len(fields))
+	}
+	d, err := time.ParseDuration(fields[5])
+	if err != nil || d <= 0 {
+		return Window{}, fmt.Errorf("invalid duration %q", fields[5])
+	}
+	w := Window{Spec: entry, Duration: d}
+	for i, f := range []struct {
+		dst      *uint64
+		min, max int
+	}{{&w.minute, 0, 59}, {&w.hour, 0, 23}, {&w.dom, 1, 31}, {&w.month, 1, 12}, {&w.dow, 0, 7}} {
+		bits, err := parseCronField(fields[i], f.min, f.max)
+		if err != nil {
+			return Window{}, fmt.Errorf("field %d %q: %w", i+1, fields[i], err)
+		}
+		*f.dst = bits
+	}
+	// Sunday is 0 or 7.
+	if w.dow&(1<<7) != 0 {
+		w.dow |= 1
+	}
+	w.domStar, w.dowStar = fields[2] == "*", fields[4] == "*"
+	return w, nil
+}
+
+// parseCronField returns the values matched by field as a bit set.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", a)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid value %q", b)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// starts reports whether the window opens at t (truncated to the minute).
+func (w Window) starts(t time.Time) bool {
+	if w.minute&(1<<t.Minute()) == 0 || w.hour&(1<<t.Hour()) == 0 || w.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	domOK := w.dom&(1<<t.Day()) != 0
+	dowOK := w.dow&(1<<int(t.Weekday())) != 0
+	// As in cron: if both day fields are restricted, either may match.
+	if !w.domStar && !w.dowStar {
+		return domOK || dowOK
+	}
+	return domOK && dowOK
+}
+
+// Open reports whether the window is open at t.
+func (w Window) Open(t time.Time) bool {
+	t = t.Truncate(time.Minute)
+	for s := t; t.Sub(s) < w.Duration; s = s.Add(-time.Minute) {
+		if w.starts(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the next time at or after t the window opens, or the zero time if it does not open within a year.
+func (w Window) Next(t time.Time) time.Time {
+	s := t.Truncate(time.Minute)
+	if s.Before(t) {
+		s = s.Add(time.Minute)
+	}
+	for end := s.AddDate(1, 0, 0); s.Before(end); {
+		switch {
+		case w.month&(1<<int(s.Month())) == 0:
+			s = time.Date(s.Year(), s.Month()+1, 1, 0, 0, 0, 0, s.Location())
+		case w.hour&(1<<s.Hour()) == 0:
+			s = time.Date(s.Year(), s.Month(), s.Day(), s.Hour()+1, 0, 0, 0, s.Location())
+		case !w.starts(s):
+			s = s.Add(time.Minute)
+		default:
+			return s
+		}
+	}
+	return time.Time{}
+}
+
+// InWindow reports whether any of ws is open at t.
+func InWindow(ws []Window, t time.Time) bool {
+	for _, w := range ws {
+		if w.Open(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextWindow returns the earliest time after t any of ws opens, or the zero time if none opens within a year.
+func NextWindow(ws []Window, t time.Time) time.Time {
+	var next time.Time
+	for _, w := range ws {
+		if n := w.Next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
@@ -0,0 +1,162 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package reload
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// at is a time on the days after epoch (Thursday, 1 January 2026, UTC).
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, 1, 1+day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseWindowsErrors(t *testing.T) {
+	for _, spec := range []string{
+		"* * * * 1h",
+		"60 * * * * 1h",
+		"* 24 * * * 1h",
+		"* * 0 * * 1h",
+		"* * * 13 * 1h",
+		"* * * * 8 1h",
+		"5-1 * * * * 1h",
+		"*/0 * * * * 1h",
+		"a * * * * 1h",
+		"* * * * * 0s",
+		"* * * * * soon",
+	} {
+		if _, err := ParseWindows(spec); err == nil {
+			t.Errorf("ParseWindows(%q) succeeded, want an error", spec)
+		}
+	}
+	ws, err := ParseWindows(" 0 2 * * * 2h ; ; 0 22 * * 6 10h ")
+	if err != nil || len(ws) != 2 {
+		t.Errorf("ParseWindows = %d windows, %v; want 2", len(ws), err)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		t        time.Time
+		wantOpen bool
+		wantNext time.Time
+	}{
+		{name: "before", spec: "0 2 * * * 2h", t: at(0, 1, 59), wantNext: at(0, 2, 0)},
+		{name: "opens", spec: "0 2 * * * 2h", t: at(0, 2, 0), wantOpen: true, wantNext: at(0, 2, 0)},
+		{name: "open", spec: "0 2 * * * 2h", t: at(0, 3, 59), wantOpen: true, wantNext: at(1, 2, 0)},
+		{name: "closed after duration", spec: "0 2 * * * 2h", t: at(0, 4, 0), wantNext: at(1, 2, 0)},
+		{name: "next rounds up to the minute", spec: "0 2 * * * 2h", t: at(0, 2, 0).Add(time.Second), wantOpen: true, wantNext: at(1, 2, 0)},
+		{name: "across midnight", spec: "0 22 * * * 4h", t: at(1, 1, 30), wantOpen: true, wantNext: at(1, 22, 0)},
+		{name: "across midnight closed", spec: "0 22 * * * 4h", t: at(1, 2, 0), wantNext: at(1, 22, 0)},
+		{name: "minute step", spec: "*/15 * * * * 5m", t: at(0, 0, 17), wantOpen: true, wantNext: at(0, 0, 30)},
+		{name: "minute step closed", spec: "*/15 * * * * 5m", t: at(0, 0, 20), wantNext: at(0, 0, 30)},
+		{name: "value with step", spec: "10/20 * * * * 1m", t: at(0, 0, 31), wantNext: at(0, 0, 50)},
+		{name: "hour range with step and weekday list", spec: "0 9-17/4 * * 1,3 1h", t: at(0, 0, 0), wantNext: at(4, 9, 0)},
+		{name: "hour range with step", spec: "0 9-17/4 * * 1,3 1h", t: at(4, 9, 30), wantOpen: true, wantNext: at(4, 13, 0)},
+		{name: "weekday range", spec: "0 0 * * 1-5 1h", t: at(1, 12, 0), wantNext: at(4, 0, 0)},
+		{name: "sunday as 7", spec: "0 0 * * 7 1h", t: at(0, 0, 0), wantNext: at(3, 0, 0)},
+		{name: "sunday as 0", spec: "0 0 * * 0 1h", t: at(3, 0, 30), wantOpen: true, wantNext: at(10, 0, 0)},
+		{name: "day of month", spec: "0 0 15 * * 1h", t: at(0, 0, 0), wantNext: at(14, 0, 0)},
+		{name: "day of month or weekday", spec: "0 0 15 * 1 1h", t: at(0, 0, 0), wantNext: at(4, 0, 0)},
+		{name: "day of month and any weekday", spec: "0 0 15 * * 1h", t: at(4, 0, 0), wantNext: at(14, 0, 0)},
+		{name: "month", spec: "0 0 1 3 * 1h", t: at(0, 0, 0), wantNext: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "never opens", spec: "0 0 31 2 * 1h", t: at(0, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, err := ParseWindows(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := InWindow(ws, tt.t); got != tt.wantOpen {
+				t.Errorf("InWindow(%s) = %t, want %t", tt.t, got, tt.wantOpen)
+			}
+			if got := NextWindow(ws, tt.t); !got.Equal(tt.wantNext) {
+				t.Errorf("NextWindow(%s) = %s, want %s", tt.t, got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	nightly, err := ParseWindows("0 2 * * * 2h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	never, err := ParseWindows("0 0 31 2 * 1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := at(0, 12, 0)
+	tests := []struct {
+		name        string
+		minInterval time.Duration
+		windows     []Window
+		urgent      []Cause
+		// at is the time of the check; zero for now.
+		at time.Time
+		// last is how long before now the last action ran; 0 for none.
+		last   time.Duration
+		causes []Cause
+		action Action
+		want   *DeferError
+	}{
+		{name: "no action", minInterval: time.Minute, last: time.Second, causes: []Cause{CauseJWT}, action: ActionNone},
+		{name: "min interval", minInterval: time.Minute, last: 10 * time.Second, causes: []Cause{CauseConfig}, action: ActionReload,
+			want: &DeferError{Action: ActionReload, Until: now.Add(50 * time.Second), Reason: DeferMinInterval}},
+		{name: "min interval passed", minInterval: time.Minute, last: time.Minute, causes: []Cause{CauseConfig}, action: ActionReload},
+		{name: "urgent skips min interval", minInterval: time.Minute, urgent: []Cause{CauseSSL}, last: time.Second, causes: []Cause{CauseSSL}, action: ActionReload},
+		{name: "manual skips min interval", minInterval: time.Minute, last: time.Second, causes: []Cause{CauseManual}, action: ActionRestart},
+		{name: "reload ignores windows", windows: nightly, causes: []Cause{CauseConfig}, action: ActionReload},
+		{name: "restart outside window", windows: nightly, causes: []Cause{CauseConfig}, action: ActionRestart,
+			want: &DeferError{Action: ActionRestart, Until: at(1, 2, 0), Reason: DeferWindow}},
+		{name: "restart in window", windows: nightly, at: at(0, 3, 0), causes: []Cause{CauseConfig}, action: ActionRestart},
+		{name: "urgent restart outside window", windows: nightly, urgent: []Cause{CauseCreds}, causes: []Cause{CauseConfig, CauseCreds}, action: ActionLameDuckRestart},
+		{name: "manual restart outside window", windows: nightly, causes: []Cause{CauseManual}, action: ActionRestart},
+		{name: "window never opens", windows: never, causes: []Cause{CauseConfig}, action: ActionRestart,
+			want: &DeferError{Action: ActionRestart, Until: now.Add(24 * time.Hour), Reason: DeferWindow}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			clock.now = now
+			if !tt.at.IsZero() {
+				clock.now = tt.at
+			}
+			l := &Limiter{MinInterval: tt.minInterval, Windows: tt.windows, Urgent: tt.urgent, Clock: clock}
+			if tt.last > 0 {
+				l.Done(clock.now.Add(-tt.last))
+			}
+			r := NewRun()
+			for _, c := range tt.causes {
+				r.causes[c] = true
+			}
+			r.Action = tt.action
+			err := l.Check(r)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Check = %v, want nil", err)
+				}
+				return
+			}
+			var d *DeferError
+			if !errors.As(err, &d) || *d != *tt.want {
+				t.Errorf("Check = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
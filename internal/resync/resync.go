@@ -0,0 +1,167 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+// Package resync detects input changes the file watchers missed by hashing the inputs, and schedules full resyncs
+// on an interval and on demand (watcher overflow or exit).
+package resync
+
+import (
+	"context"
+	"crypto/sha256"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datasance/nats-server/internal/reload"
+)
+
+// Triggers of a resync, used as the "trigger" label of pot_nats_resyncs_total.
+const (
+	TriggerInterval    = "interval"
+	TriggerOverflow    = "overflow"
+	TriggerWatcherExit = "watcher_exit"
+)
+
+// Input is a watched input: files and directories whose content change is reported as Cause.
+type Input struct {
+	Cause reload.Cause
+	Paths []string
+}
+
+// Resyncer keeps a content digest of each input and runs resyncs one at a time.
+type Resyncer struct {
+	inputs []Input
+	kick   chan string
+
+	mu      sync.Mutex
+	digests map[reload.Cause][sha256.Size]byte
+}
+
+// New returns a resyncer for inputs, taking their current content as seen.
+func New(inputs []Input) *Resyncer {
+	s := &Resyncer{inputs: inputs, kick: make(chan string, 1), digests: make(map[reload.Cause][sha256.Size]byte)}
+	for _, in := range inputs {
+		s.digests[in.Cause] = digest(in.Paths)
+	}
+	return s
+}
+
+// Refresh takes the current content of the inputs of causes as seen, e.g. after a pipeline run handled them, so the
+// next resync does not report them again.
+func (s *Resyncer) Refresh(causes ...reload.Cause) {
+	for _, in := range s.inputs {
+		for _, c := range causes {
+			if in.Cause == c {
+				d := digest(in.Paths)
+				s.mu.Lock()
+				s.digests[c] = d
+				s.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Changed returns the causes whose input content differs from what was last seen, and takes the current content as
+// seen.
+func (s *Resyncer) Changed() []reload.Cause {
+	var out []reload.Cause
+	for _, in := range s.inputs {
+		d := digest(in.Paths)
+		s.mu.Lock()
+		if s.digests[in.Cause] != d {
+			s.digests[in.Cause] = d
+			out = append(out, in.Cause)
+		}
+		s.mu.Unlock()
+	}
+	return out
+}
+
+// Kick requests a resync now. Requests while one is already queued are merged.
+func (s *Resyncer) Kick(trigger string) {
+	select {
+	case s.kick <- trigger:
+	default:
+	}
+}
+
+// Run calls resync every interval (0 disables the interval) and on each Kick, one at a time, until ctx is done.
+func (s *Resyncer) Run(ctx context.Context, interval time.Duration, resync func(ctx context.Context, trigger string)) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			resync(ctx, TriggerInterval)
+		case trigger := <-s.kick:
+			resync(ctx, trigger)
+		}
+	}
+}
+
+// digest hashes the names and content of the regular files at paths, walking directories. Kubernetes volume
+// internals (names starting with "..") are skipped; their content is reached through the visible symlinks. Missing
+// paths hash as empty.
+func digest(paths []string) [sha256.Size]byte {
+	h := sha256.New()
+	add := func(path string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		h.Write([]byte(path))
+		h.Write([]byte{0})
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			add(p)
+			continue
+		}
+		// WalkDir does not follow a symlinked root.
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			p = resolved
+		}
+		filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if strings.HasPrefix(d.Name(), "..") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				add(path)
+			}
+			return nil
+		})
+	}
+	var out [sha256.Size]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
@@ -0,0 +1,111 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package status
+
+import (
+	"sync"
+	"time"
+)
+
+// Result is the outcome of one pipeline step (JWT sync, reload, ...). Zero Time means the step has not run yet.
+type Result struct {
+	Time   time.Time `json:"time,omitempty"`
+	Causes []string  `json:"causes,omitempty"`
+	Action string    `json:"action,omitempty"`
+	// Outcome is the metrics outcome of a reload (e.g. "rejected"); empty for other steps.
+	Outcome string `json:"outcome,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// OK reports whether the step has not run yet or its last run succeeded.
+func (r Result) OK() bool {
+	return r.Error == ""
+}
+
+// Expiry is the result of the last expiry scan. Zero Time means no scan has run.
+type Expiry struct {
+	Time     time.Time `json:"time,omitempty"`
+	Expired  []string  `json:"expired,omitempty"`
+	Expiring []string  `json:"expiring,omitempty"`
+}
+
+// Snapshot is a point-in-time copy of the pipeline state, safe to marshal as JSON.
+type Snapshot struct {
+	Restarting bool   `json:"restarting"`
+	Stopping   bool   `json:"stopping"`
+	LastSync   Result `json:"last_sync"`
+	LastReload Result `json:"last_reload"`
+	Expiry     Expiry `json:"expiry"`
+}
+
+// Tracker records the state of the wrapper pipeline for health checks and status dumps. Safe for concurrent use.
+type Tracker struct {
+	mu   sync.Mutex
+	snap Snapshot
+}
+
+// SyncDone records the result of a mount to JWT dir sync.
+func (t *Tracker) SyncDone(err error) {
+	t.mu.Lock()
+	t.snap.LastSync = result(nil, "", err)
+	t.mu.Unlock()
+}
+
+// ReloadDone records the result of a reload or restart decision for causes. action is e.g. "reload" or "restart";
+// outcome is the metrics outcome (e.g. "rejected").
+func (t *Tracker) ReloadDone(causes []string, action, outcome string, err error) {
+	t.mu.Lock()
+	t.snap.LastReload = result(causes, action, err)
+	t.snap.LastReload.Outcome = outcome
+	t.mu.Unlock()
+}
+
+// ExpiryDone records the result of an expiry scan.
+func (t *Tracker) ExpiryDone(e Expiry) {
+	t.mu.Lock()
+	t.snap.Expiry = e
+	t.mu.Unlock()
+}
+
+// SetRestarting marks the child as being restarted by the wrapper (stopped on purpose, not crashed).
+func (t *Tracker) SetRestarting(v bool) {
+	t.mu.Lock()
+	t.snap.Restarting = v
+	t.mu.Unlock()
+}
+
+// SetStopping marks the child as being stopped for good on request (e.g. lame duck mode before shutdown).
+func (t *Tracker) SetStopping(v bool) {
+	t.mu.Lock()
+	t.snap.Stopping = v
+	t.mu.Unlock()
+}
+
+// Snapshot returns a copy of the current state.
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.snap
+	s.LastReload.Causes = append([]string(nil), s.LastReload.Causes...)
+	s.Expiry.Expired = append([]string(nil), s.Expiry.Expired...)
+	s.Expiry.Expiring = append([]string(nil), s.Expiry.Expiring...)
+	return s
+}
+
+func result(causes []string, action string, err error) Result {
+	r := Result{Time: time.Now().UTC(), Causes: append([]string(nil), causes...), Action: action}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
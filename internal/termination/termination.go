@@ -0,0 +1,126 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package termination
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/datasance/nats-server/internal/logging"
+)
+
+// MaxSize is the Kubernetes limit for a container termination message; longer messages are truncated by the kubelet.
+const MaxSize = 4096
+
+const (
+	// maxErrorLines is the number of most recent error lines included in the summary.
+	maxErrorLines = 10
+	// maxLineLen caps each included log line.
+	maxLineLen = 300
+)
+
+// Summary describes why the wrapper is exiting. err is the child's exit error, if any; its exit code and signal
+// are included when available. The most recent nats-server [ERR]/[FTL] lines and wrapper errors from entries
+// follow, newest last, within MaxSize.
+func Summary(reason string, err error, entries []logging.Entry) string {
+	var b strings.Builder
+	b.WriteString(reason)
+	if err != nil {
+		b.WriteString(": " + err.Error())
+	}
+	b.WriteByte('\n')
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		fmt.Fprintf(&b, "exit code: %d\n", exitErr.ExitCode())
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			fmt.Fprintf(&b, "signal: %s\n", ws.Signal())
+		}
+	}
+	lines := errorLines(entries)
+	if len(lines) == 0 {
+		return truncate(b.String())
+	}
+	b.WriteString("last errors:\n")
+	// Drop the oldest lines first when the message would not fit.
+	for len(lines) > 0 && b.Len()+joinedLen(lines) > MaxSize {
+		lines = lines[1:]
+	}
+	for _, l := range lines {
+		b.WriteString(l + "\n")
+	}
+	return truncate(b.String())
+}
+
+// Write writes msg to path (e.g. /dev/termination-log). An empty path disables the termination message.
+func Write(path, msg string) error {
+	if path == "" {
+		return nil
+	}
+	return os.WriteFile(path, []byte(truncate(msg)), 0644)
+}
+
+// errorLines formats the last maxErrorLines nats-server [ERR]/[FTL] lines and wrapper error records.
+func errorLines(entries []logging.Entry) []string {
+	var out []string
+	for _, e := range entries {
+		if !isError(e) {
+			continue
+		}
+		line := e.Time.Format(time.RFC3339) + " "
+		if e.NatsLevel != "" {
+			line += "[" + e.NatsLevel + "] "
+		} else if e.Origin != "" {
+			line += e.Origin + ": "
+		}
+		line += e.Message
+		if e.Error != "" {
+			line += ": " + e.Error
+		}
+		if len(line) > maxLineLen {
+			line = line[:maxLineLen] + "..."
+		}
+		out = append(out, line)
+	}
+	if len(out) > maxErrorLines {
+		out = out[len(out)-maxErrorLines:]
+	}
+	return out
+}
+
+func isError(e logging.Entry) bool {
+	if e.Origin == logging.SourceNatsServer {
+		return e.NatsLevel == "ERR" || e.NatsLevel == "FTL"
+	}
+	return e.Level >= slog.LevelError
+}
+
+func joinedLen(lines []string) int {
+	n := 0
+	for _, l := range lines {
+		n += len(l) + 1
+	}
+	return n
+}
+
+func truncate(s string) string {
+	if len(s) > MaxSize {
+		return s[:MaxSize]
+	}
+	return s
+}
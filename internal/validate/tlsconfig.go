@@ -0,0 +1,220 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package validate
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxIncludeDepth bounds include recursion in the server config, against include cycles.
+const maxIncludeDepth = 10
+
+var (
+	tlsBlockRe = regexp.MustCompile(`\btls\s*[:=]?\s*\{`)
+	tlsFieldRe = regexp.MustCompile(`\b(cert_file|key_file|ca_file)\s*[:=]?\s*("[^"]*"|'[^']*'|[^\s,}#]+)`)
+	includeRe  = regexp.MustCompile(`^include\s+("[^"]*"|'[^']*'|\S+)`)
+	credsRe    = regexp.MustCompile(`\bcredentials\s*[:=]?\s*("[^"]*"|'[^']*'|[^\s,}#]+)`)
+)
+
+// TLSSet is the certificate, key and CA of one tls block in the server config. Empty fields are not set.
+type TLSSet struct {
+	// Source is the config file and line of the block.
+	Source string
+	Cert   string
+	Key    string
+	CA     string
+}
+
+// ConfigRefs are the files referenced by the server config and the files it includes.
+type ConfigRefs struct {
+	TLS []TLSSet
+	// Creds are credentials files, e.g. of leafnode remotes.
+	Creds []string
+}
+
+// ParseConfigRefs returns the tls blocks and credentials files of the server config at path and the files it
+// includes. Relative paths are resolved against the directory of the file they appear in; values from variables
+// ($VAR) are skipped.
+func ParseConfigRefs(path string) (ConfigRefs, error) {
+	var refs ConfigRefs
+	err := parseConfigRefs(path, 0, &refs)
+	return refs, err
+}
+
+// TLSSets returns the tls blocks of the server config at path; see ParseConfigRefs.
+func TLSSets(path string) ([]TLSSet, error) {
+	refs, err := ParseConfigRefs(path)
+	return refs.TLS, err
+}
+
+func parseConfigRefs(path string, depth int, refs *ConfigRefs) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: includes nested deeper than %d", path, maxIncludeDepth)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dir := filepath.Dir(path)
+	resolve := func(v string) string {
+		v = strings.Trim(v, `"'`)
+		if v == "" || strings.HasPrefix(v, "$") || filepath.IsAbs(v) {
+			return v
+		}
+		return filepath.Join(dir, v)
+	}
+
+	var cur *TLSSet
+	depthInBlock := 0
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if m := includeRe.FindStringSubmatch(line); m != nil {
+			if err := parseConfigRefs(resolve(m[1]), depth+1, refs); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, m := range credsRe.FindAllStringSubmatch(line, -1) {
+			if v := resolve(m[1]); !strings.HasPrefix(v, "$") {
+				refs.Creds = append(refs.Creds, v)
+			}
+		}
+		if cur == nil {
+			loc := tlsBlockRe.FindStringIndex(line)
+			if loc == nil {
+				continue
+			}
+			cur = &TLSSet{Source: fmt.Sprintf("%s:%d", path, n)}
+			depthInBlock = 0
+			line = line[loc[1]-1:]
+		}
+		for _, m := range tlsFieldRe.FindAllStringSubmatch(line, -1) {
+			v := resolve(m[2])
+			if strings.HasPrefix(v, "$") {
+				continue
+			}
+			switch m[1] {
+			case "cert_file":
+				cur.Cert = v
+			case "key_file":
+				cur.Key = v
+			case "ca_file":
+				cur.CA = v
+			}
+		}
+		depthInBlock += strings.Count(line, "{") - strings.Count(line, "}")
+		if depthInBlock <= 0 {
+			if cur.Cert != "" || cur.Key != "" || cur.CA != "" {
+				refs.TLS = append(refs.TLS, *cur)
+			}
+			cur = nil
+		}
+	}
+	return scanner.Err()
+}
+
+// stripComment cuts a # or // comment off line, so braces in comments do not count. A comment starts the line or
+// follows whitespace; quoted strings and unquoted URLs (nats://host) are kept.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case i > 0 && line[i-1] != ' ' && line[i-1] != '\t':
+		case c == '#', c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// TLSConfig checks every tls block of the server config at path with TLS.
+func TLSConfig(path string, now time.Time) []Finding {
+	sets, err := TLSSets(path)
+	if err != nil {
+		return []Finding{fail("tls", path, err)}
+	}
+	var out []Finding
+	for _, set := range sets {
+		out = append(out, TLS(set, now)...)
+	}
+	return out
+}
+
+// TLS checks a set: the certificate must parse and be valid at now, the key must match it, and it must verify
+// against the CA. A CA alone must parse and be valid.
+func TLS(set TLSSet, now time.Time) []Finding {
+	var out []Finding
+	var ca []*x509.Certificate
+	if set.CA != "" {
+		certs, err := ParseCertificates(set.CA)
+		if err != nil {
+			return append(out, fail("tls", set.CA, err))
+		}
+		ca = certs
+		out = append(out, validity("tls", set.CA, certs[0], now))
+	}
+	if set.Cert == "" {
+		if set.Key != "" {
+			out = append(out, fail("tls", set.Key, errors.New("key_file without cert_file")))
+		}
+		return out
+	}
+	chain, err := ParseCertificates(set.Cert)
+	if err != nil {
+		return append(out, fail("tls", set.Cert, err))
+	}
+	out = append(out, validity("tls", set.Cert, chain[0], now))
+	if set.Key != "" {
+		if _, err := tls.LoadX509KeyPair(set.Cert, set.Key); err != nil {
+			out = append(out, fail("tls", set.Key, fmt.Errorf("does not match %s: %w", set.Cert, err)))
+		} else {
+			out = append(out, ok("tls", set.Key, "matches "+set.Cert))
+		}
+	}
+	if len(ca) > 0 {
+		if err := VerifyChain(chain, ca, now); err != nil {
+			out = append(out, fail("tls", set.Cert, fmt.Errorf("does not verify against %s: %w", set.CA, err)))
+		} else {
+			out = append(out, ok("tls", set.Cert, "verifies against "+set.CA))
+		}
+	}
+	return out
+}
+
+// validity fails a certificate that is not yet valid and otherwise reports its expiry.
+func validity(check, path string, cert *x509.Certificate, now time.Time) Finding {
+	if now.Before(cert.NotBefore) {
+		return fail(check, path, fmt.Errorf("not valid before %s", cert.NotBefore.UTC().Format(time.RFC3339)))
+	}
+	return expiry(check, path, cert.NotAfter, now)
+}
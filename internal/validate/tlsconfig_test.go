@@ -0,0 +1,347 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package validate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFiles writes files (relative name to content) under dir, creating parent directories.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseConfigRefs(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// want holds paths relative to the test dir; absolute paths are kept.
+		want ConfigRefs
+	}{
+		{
+			name: "one line",
+			files: map[string]string{"nats.conf": `port: 4222
+tls { cert_file: "server.pem", key_file: "server-key.pem", ca_file: "ca.pem" }
+`},
+			want: ConfigRefs{TLS: []TLSSet{{Source: "nats.conf:2", Cert: "server.pem", Key: "server-key.pem", CA: "ca.pem"}}},
+		},
+		{
+			name: "several lines",
+			files: map[string]string{"nats.conf": `listen: 0.0.0.0:4222
+tls {
+  cert_file: "./certs/server.pem"
+  key_file = certs/server-key.pem
+  ca_file ca.pem
+  verify: true
+}
+`},
+			want: ConfigRefs{TLS: []TLSSet{{Source: "nats.conf:2", Cert: "certs/server.pem", Key: "certs/server-key.pem", CA: "ca.pem"}}},
+		},
+		{
+			name: "nested leafnode remote",
+			files: map[string]string{"nats.conf": `leafnodes {
+  remotes [
+    {
+      url: "tls://hub:7422"
+      credentials: "leaf.creds"
+      tls {
+        cert_file: "leaf.pem"
+        key_file: "leaf-key.pem"
+      }
+    }
+  ]
+}
+tls: {
+  ca_file: "ca.pem"
+}
+`},
+			want: ConfigRefs{
+				TLS: []TLSSet{
+					{Source: "nats.conf:6", Cert: "leaf.pem", Key: "leaf-key.pem"},
+					{Source: "nats.conf:13", CA: "ca.pem"},
+				},
+				Creds: []string{"leaf.creds"},
+			},
+		},
+		{
+			name: "quoted and absolute paths",
+			files: map[string]string{"nats.conf": `tls {
+  cert_file: 'certs/my server.pem'
+  key_file: "/etc/nats/ssl/server-key.pem"
+  ca_file: /etc/nats/ssl/ca.pem
+}
+`},
+			want: ConfigRefs{TLS: []TLSSet{{Source: "nats.conf:1", Cert: "certs/my server.pem", Key: "/etc/nats/ssl/server-key.pem", CA: "/etc/nats/ssl/ca.pem"}}},
+		},
+		{
+			name: "include",
+			files: map[string]string{
+				"nats.conf": `port: 4222
+include "tls.conf"
+include ./leaf/leaf.conf
+`,
+				"tls.conf": `tls {
+  cert_file: server.pem
+  key_file: server-key.pem
+}
+`,
+				"leaf/leaf.conf": `leafnodes {
+  remotes = [ { url: "nats://hub:7422", credentials: "leaf.creds", tls { ca_file: "../ca.pem" } } ]
+}
+`,
+			},
+			want: ConfigRefs{
+				TLS: []TLSSet{
+					{Source: "tls.conf:1", Cert: "server.pem", Key: "server-key.pem"},
+					{Source: "leaf/leaf.conf:2", CA: "ca.pem"},
+				},
+				Creds: []string{"leaf/leaf.creds"},
+			},
+		},
+		{
+			name: "variables are skipped",
+			files: map[string]string{"nats.conf": `CERT: "/etc/nats/ssl/server.pem"
+tls {
+  cert_file: $CERT
+  key_file: "server-key.pem"
+}
+leafnodes { remotes [ { url: "nats://hub:7422", credentials: $LEAF_CREDS } ] }
+`},
+			want: ConfigRefs{TLS: []TLSSet{{Source: "nats.conf:2", Key: "server-key.pem"}}},
+		},
+		{
+			name: "comments with braces",
+			files: map[string]string{"nats.conf": `# tls { cert_file: "commented.pem" }
+tls { // client listener {
+  cert_file: "server.pem" # was: { "old.pem"
+  // key_file: "old-key.pem" }
+  key_file: "server-key.pem"
+}
+cluster {
+  routes = [nats-route://node-1:6222, nats-route://node-2:6222]
+  tls {
+    ca_file: ca.pem # {
+  }
+}
+`},
+			want: ConfigRefs{TLS: []TLSSet{
+				{Source: "nats.conf:2", Cert: "server.pem", Key: "server-key.pem"},
+				{Source: "nats.conf:9", CA: "ca.pem"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			abs := func(p string) string {
+				if p == "" || filepath.IsAbs(p) {
+					return p
+				}
+				return filepath.Join(dir, p)
+			}
+			want := ConfigRefs{}
+			for _, set := range tt.want.TLS {
+				want.TLS = append(want.TLS, TLSSet{Source: abs(set.Source), Cert: abs(set.Cert), Key: abs(set.Key), CA: abs(set.CA)})
+			}
+			for _, c := range tt.want.Creds {
+				want.Creds = append(want.Creds, abs(c))
+			}
+			got, err := ParseConfigRefs(filepath.Join(dir, "nats.conf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseConfigRefs:\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseConfigRefsIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"nats.conf": "include nats.conf\n"})
+	if _, err := ParseConfigRefs(filepath.Join(dir, "nats.conf")); err == nil || !strings.Contains(err.Error(), "nested deeper") {
+		t.Fatalf("err = %v, want include depth error", err)
+	}
+}
+
+// testCert is a throwaway certificate and key written as PEM files.
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCert writes a certificate for name valid from notBefore to notAfter, signed by parent (self-signed CA if
+// parent is nil), to dir.
+func newTestCert(t *testing.T, dir, name string, parent *testCert, notBefore, notAfter time.Time) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{name},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &testCert{cert: cert, key: key, certFile: filepath.Join(dir, name+".pem"), keyFile: filepath.Join(dir, name+"-key.pem")}
+	writeFiles(t, dir, map[string]string{
+		name + ".pem":     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		name + "-key.pem": string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	})
+	return c
+}
+
+func TestTLS(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	from, to := now.Add(-24*time.Hour), now.Add(365*24*time.Hour)
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil, from, to)
+	otherCA := newTestCert(t, dir, "other-ca", nil, from, to)
+	server := newTestCert(t, dir, "server", ca, from, to)
+	other := newTestCert(t, dir, "other", ca, from, to)
+	expired := newTestCert(t, dir, "expired", ca, from.Add(-48*time.Hour), from)
+	future := newTestCert(t, dir, "future", ca, to, to.Add(24*time.Hour))
+
+	type want struct {
+		path     string
+		severity Severity
+		// message, if set, must be part of the finding's message.
+		message string
+	}
+	tests := []struct {
+		name string
+		set  TLSSet
+		want []want
+	}{
+		{
+			name: "valid",
+			set:  TLSSet{Cert: server.certFile, Key: server.keyFile, CA: ca.certFile},
+			want: []want{
+				{ca.certFile, SeverityOK, "expires at"},
+				{server.certFile, SeverityOK, "expires at"},
+				{server.keyFile, SeverityOK, "matches"},
+				{server.certFile, SeverityOK, "verifies against"},
+			},
+		},
+		{
+			name: "key does not match cert",
+			set:  TLSSet{Cert: server.certFile, Key: other.keyFile},
+			want: []want{
+				{server.certFile, SeverityOK, ""},
+				{other.keyFile, SeverityFail, "does not match " + server.certFile},
+			},
+		},
+		{
+			name: "chain does not verify",
+			set:  TLSSet{Cert: server.certFile, Key: server.keyFile, CA: otherCA.certFile},
+			want: []want{
+				{otherCA.certFile, SeverityOK, ""},
+				{server.certFile, SeverityOK, ""},
+				{server.keyFile, SeverityOK, ""},
+				{server.certFile, SeverityFail, "does not verify against " + otherCA.certFile},
+			},
+		},
+		{
+			name: "expired cert",
+			set:  TLSSet{Cert: expired.certFile, CA: ca.certFile},
+			want: []want{
+				{ca.certFile, SeverityOK, ""},
+				{expired.certFile, SeverityFail, "expired at"},
+				{expired.certFile, SeverityFail, "does not verify"},
+			},
+		},
+		{
+			name: "cert not yet valid",
+			set:  TLSSet{Cert: future.certFile},
+			want: []want{{future.certFile, SeverityFail, "not valid before"}},
+		},
+		{
+			name: "key without cert",
+			set:  TLSSet{Key: server.keyFile},
+			want: []want{{server.keyFile, SeverityFail, "key_file without cert_file"}},
+		},
+		{
+			name: "CA alone",
+			set:  TLSSet{CA: ca.certFile},
+			want: []want{{ca.certFile, SeverityOK, ""}},
+		},
+		{
+			name: "cert file missing",
+			set:  TLSSet{Cert: filepath.Join(dir, "missing.pem"), Key: server.keyFile},
+			want: []want{{filepath.Join(dir, "missing.pem"), SeverityFail, "no such file"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TLS(tt.set, now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("TLS() = %+v, want %d findings", got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				f := got[i]
+				if f.Check != "tls" || f.Path != w.path || f.Severity != w.severity || !strings.Contains(f.Message, w.message) {
+					t.Errorf("finding %d = %+v, want path %s severity %s message containing %q", i, f, w.path, w.severity, w.message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package validate
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/datasance/nats-server/internal/config"
+	"github.com/nats-io/jwt/v2"
+)
+
+// configTestTimeout bounds the nats-server -t run.
+const configTestTimeout = 30 * time.Second
+
+// Severity of a Finding.
+type Severity string
+
+const (
+	SeverityOK   Severity = "ok"
+	SeverityWarn Severity = "warn"
+	SeverityFail Severity = "fail"
+)
+
+// Finding is the result of one offline check.
+type Finding struct {
+	Check    string   `json:"check"`
+	Path     string   `json:"path"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message,omitempty"`
+}
+
+// Report collects findings.
+type Report struct {
+	Findings []Finding `json:"findings"`
+}
+
+// Add appends findings to the report.
+func (r *Report) Add(f ...Finding) {
+	r.Findings = append(r.Findings, f...)
+}
+
+// Failed reports whether any finding has SeverityFail.
+func (r *Report) Failed() bool {
+	for _, f := range r.Findings {
+		if f.Severity == SeverityFail {
+			return true
+		}
+	}
+	return false
+}
+
+func ok(check, path, msg string) Finding {
+	return Finding{Check: check, Path: path, Severity: SeverityOK, Message: msg}
+}
+
+func warn(check, path, msg string) Finding {
+	return Finding{Check: check, Path: path, Severity: SeverityWarn, Message: msg}
+}
+
+func fail(check, path string, err error) Finding {
+	return Finding{Check: check, Path: path, Severity: SeverityFail, Message: err.Error()}
+}
+
+// Config checks the server config at path with "nats-server -t" using the binary at bin. If bin does not exist,
+// only readability is checked and the syntax check is reported as a warning.
+func Config(bin, path string) Finding {
+	if _, err := os.ReadFile(path); err != nil {
+		return fail("config", path, err)
+	}
+	if _, err := os.Stat(bin); err != nil {
+		return warn("config", path, fmt.Sprintf("readable; syntax not checked (nats-server binary %s not found)", bin))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), configTestTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, bin, "-t", "-c", path).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fail("config", path, errors.New(msg))
+	}
+	return ok("config", path, "nats-server -t passed")
+}
+
+// File checks that an optional input file (e.g. the accounts config) is readable. A missing file is a warning.
+func File(check, path string) Finding {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return warn(check, path, "not present")
+	}
+	if _, err := os.ReadFile(path); err != nil {
+		return fail(check, path, err)
+	}
+	return ok(check, path, "")
+}
+
+// TLSDir checks every certificate (*.crt, *.pem) in dir: it must parse and not be expired. If tls.crt and tls.key
+// exist they must form a key pair and, if ca.crt exists, tls.crt must verify against it. A missing dir is a warning.
+func TLSDir(dir string, now time.Time) []Finding {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Finding{warn("tls", dir, "not present")}
+	}
+	if err != nil {
+		return []Finding{fail("tls", dir, err)}
+	}
+	var out []Finding
+	certs := make(map[string][]*x509.Certificate)
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !(strings.HasSuffix(name, ".crt") || strings.HasSuffix(name, ".pem")) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		chain, err := ParseCertificates(path)
+		if err != nil {
+			out = append(out, fail("tls", path, err))
+			continue
+		}
+		certs[name] = chain
+		out = append(out, expiry("tls", path, chain[0].NotAfter, now))
+	}
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if _, err := os.Stat(keyPath); err == nil {
+		if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+			out = append(out, fail("tls", keyPath, fmt.Errorf("does not match tls.crt: %w", err)))
+		} else {
+			out = append(out, ok("tls", keyPath, "matches tls.crt"))
+		}
+	}
+	if leaf, ca := certs["tls.crt"], certs["ca.crt"]; len(leaf) > 0 && len(ca) > 0 {
+		if err := VerifyChain(leaf, ca, now); err != nil {
+			out = append(out, fail("tls", certPath, fmt.Errorf("does not verify against ca.crt: %w", err)))
+		} else {
+			out = append(out, ok("tls", certPath, "verifies against ca.crt"))
+		}
+	}
+	return out
+}
+
+// ParseCertificates parses all PEM certificates in the file at path.
+func ParseCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cert)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return out, nil
+}
+
+// VerifyChain verifies leaf[0] (with leaf[1:] as intermediates) against the roots at time now.
+func VerifyChain(leaf, roots []*x509.Certificate, now time.Time) error {
+	pool := x509.NewCertPool()
+	for _, c := range roots {
+		pool.AddCert(c)
+	}
+	inter := x509.NewCertPool()
+	for _, c := range leaf[1:] {
+		inter.AddCert(c)
+	}
+	_, err := leaf[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: inter,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// JWTDir decodes every account JWT (*.jwt) in dir: the signature must be valid, the subject must match the file
+// name (account-pub-key.jwt), and the claims must not be expired or have blocking validation issues.
+func JWTDir(dir string, now time.Time) []Finding {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Finding{warn("jwt", dir, "not present")}
+	}
+	if err != nil {
+		return []Finding{fail("jwt", dir, err)}
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".jwt") && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return []Finding{warn("jwt", dir, "no account JWTs")}
+	}
+	out := make([]Finding, 0, len(names))
+	for _, name := range names {
+		out = append(out, accountJWT(filepath.Join(dir, name), strings.TrimSuffix(name, ".jwt"), now))
+	}
+	return out
+}
+
+func accountJWT(path, account string, now time.Time) Finding {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fail("jwt", path, err)
+	}
+	claims, err := jwt.DecodeAccountClaims(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return fail("jwt", path, err)
+	}
+	if claims.Subject != account {
+		return fail("jwt", path, fmt.Errorf("subject %s does not match file name", claims.Subject))
+	}
+	if err := blocking(claims); err != nil {
+		return fail("jwt", path, err)
+	}
+	return expiryUnix("jwt", path, claims.Expires, now)
+}
+
+// Creds checks a decorated creds file (user JWT + nkey seed): the JWT must decode, not be expired, and its subject
+// must be the public key of the seed.
+func Creds(path string, now time.Time) Finding {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fail("creds", path, err)
+	}
+	token, err := jwt.ParseDecoratedJWT(data)
+	if err != nil {
+		return fail("creds", path, fmt.Errorf("user JWT: %w", err))
+	}
+	claims, err := jwt.DecodeUserClaims(token)
+	if err != nil {
+		return fail("creds", path, fmt.Errorf("user JWT: %w", err))
+	}
+	kp, err := jwt.ParseDecoratedUserNKey(data)
+	if err != nil {
+		return fail("creds", path, fmt.Errorf("nkey seed: %w", err))
+	}
+	pub, err := kp.PublicKey()
+	if err != nil {
+		return fail("creds", path, fmt.Errorf("nkey seed: %w", err))
+	}
+	if pub != claims.Subject {
+		return fail("creds", path, fmt.Errorf("seed public key %s does not match JWT subject %s", pub, claims.Subject))
+	}
+	if err := blocking(claims); err != nil {
+		return fail("creds", path, err)
+	}
+	return expiryUnix("creds", path, claims.Expires, now)
+}
+
+// InspectCreds checks a creds file with Creds, that the account issuing its user JWT is in jwtDir (skipped if jwtDir
+// is empty), and that the file is not world-readable.
+func InspectCreds(path, jwtDir string, now time.Time) []Finding {
+	out := []Finding{Creds(path, now)}
+	if out[0].Severity == SeverityFail {
+		return out
+	}
+	if jwtDir != "" {
+		out = append(out, CredsIssuer(path, jwtDir))
+	}
+	return append(out, CredsPermissions(path))
+}
+
+// CredsError returns an error listing the failed InspectCreds checks of the creds file at path, or nil if it is
+// usable. Clients check it before connecting with the system account creds.
+func CredsError(path, jwtDir string, now time.Time) error {
+	var errs []error
+	for _, f := range InspectCreds(path, jwtDir, now) {
+		if f.Severity == SeverityFail {
+			errs = append(errs, fmt.Errorf("%s: %s", f.Path, f.Message))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CredsIssuer checks that the account of a creds file's user JWT has a JWT in jwtDir (<account>.jwt) and, if the user
+// was signed with a signing key, that the account lists that key. A missing account JWT is only a warning: the server
+// may have it from elsewhere (e.g. the system account in resolver_preload).
+func CredsIssuer(path, jwtDir string) Finding {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fail("creds", path, err)
+	}
+	token, err := jwt.ParseDecoratedJWT(data)
+	if err != nil {
+		return fail("creds", path, fmt.Errorf("user JWT: %w", err))
+	}
+	claims, err := jwt.DecodeUserClaims(token)
+	if err != nil {
+		return fail("creds", path, fmt.Errorf("user JWT: %w", err))
+	}
+	account := claims.IssuerAccount
+	if account == "" {
+		account = claims.Issuer
+	}
+	jwtPath := filepath.Join(jwtDir, account+".jwt")
+	raw, err := os.ReadFile(jwtPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return warn("creds", path, fmt.Sprintf("issuer account %s not in %s; signing key not checked", account, jwtDir))
+	}
+	if err != nil {
+		return fail("creds", path, fmt.Errorf("issuer account %s: %w", account, err))
+	}
+	ac, err := jwt.DecodeAccountClaims(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return fail("creds", path, fmt.Errorf("issuer account %s: %w", account, err))
+	}
+	if claims.IssuerAccount != "" && !ac.SigningKeys.Contains(claims.Issuer) {
+		return fail("creds", path, fmt.Errorf("signing key %s is not a signing key of account %s", claims.Issuer, account))
+	}
+	return ok("creds", path, "issued by account "+account)
+}
+
+// CredsPermissions warns if a creds file is readable by everyone: it holds the user's private seed.
+func CredsPermissions(path string) Finding {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fail("creds", path, err)
+	}
+	if mode := info.Mode().Perm(); mode&0o004 != 0 {
+		return warn("creds", path, fmt.Sprintf("world-readable (mode %04o); it contains the nkey seed", mode))
+	}
+	return ok("creds", path, "not world-readable")
+}
+
+// CredsFiles returns the creds files (*.creds) in dir, sorted. A missing dir has none.
+func CredsFiles(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.creds"))
+	sort.Strings(matches)
+	return matches
+}
+
+// blocking returns the blocking validation issues of claims, if any. Expiry is a time check, reported by expiry.
+func blocking(c jwt.Claims) error {
+	vr := jwt.CreateValidationResults()
+	c.Validate(vr)
+	if errs := vr.Errors(); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func expiryUnix(check, path string, expires int64, now time.Time) Finding {
+	if expires == 0 {
+		return ok(check, path, "no expiry")
+	}
+	return expiry(check, path, time.Unix(expires, 0), now)
+}
+
+// warnWithin is the widest NATS_EXPIRY_WARN_DAYS threshold: an item expiring within it is a warning here and
+// expiring for the expiry monitor of the wrapper.
+func warnWithin() time.Duration {
+	var d time.Duration
+	for _, t := range config.GetNatsExpiryWarnDays() {
+		d = max(d, t)
+	}
+	return d
+}
+
+func expiry(check, path string, notAfter, now time.Time) Finding {
+	switch left := notAfter.Sub(now); {
+	case left <= 0:
+		return fail(check, path, fmt.Errorf("expired at %s", notAfter.UTC().Format(time.RFC3339)))
+	case left <= warnWithin():
+		return warn(check, path, fmt.Sprintf("expires at %s", notAfter.UTC().Format(time.RFC3339)))
+	}
+	return ok(check, path, "expires at "+notAfter.UTC().Format(time.RFC3339))
+}
@@ -14,46 +14,64 @@ package watch
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
 	"github.com/fsnotify/fsnotify"
 )
 
-const defaultDebounce = 500 * time.Millisecond
+var logger = logging.Component("watch")
+
+// ErrWatcherClosed is returned by the watchers when fsnotify closes their channels.
+var ErrWatcherClosed = errors.New("watcher closed")
 
 // WatchConfigFile watches the config file at configPath for changes. On write/create
-// (after debounce), it calls onReload. Runs until ctx is cancelled.
+// (after debounce), it calls onReload with the changed path; with debounce <= 0 it calls onReload on every event.
+// If the event queue overflowed (events were lost), it calls onOverflow, if set.
+// Runs until ctx is cancelled (returning nil) or the watcher fails (returning the error; see Supervise).
 // The parent directory of configPath must exist (e.g. volume-mounted).
-func WatchConfigFile(ctx context.Context, configPath string, debounce time.Duration, onReload func()) {
-	if debounce <= 0 {
-		debounce = defaultDebounce
-	}
+func WatchConfigFile(ctx context.Context, configPath string, debounce time.Duration, onReload func(paths []string), onOverflow func()) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Printf("ERROR: Failed to create fsnotify watcher for config file: %v", err)
-		return
+		return fmt.Errorf("create fsnotify watcher: %w", err)
 	}
 	defer watcher.Close()
 
 	dir := filepath.Dir(configPath)
 	if err := watcher.Add(dir); err != nil {
-		log.Printf("ERROR: Failed to add watch on %s: %v", dir, err)
-		return
+		return fmt.Errorf("add watch %s: %w", dir, err)
 	}
 
 	var debounceTimer *time.Timer
 	var debounceMu sync.Mutex
-	scheduleReload := func() {
+	changed := make(map[string]struct{})
+	scheduleReload := func(path string) {
+		if debounce <= 0 {
+			onReload([]string{path})
+			return
+		}
 		debounceMu.Lock()
+		changed[path] = struct{}{}
 		if debounceTimer != nil {
 			debounceTimer.Stop()
 		}
 		debounceTimer = time.AfterFunc(debounce, func() {
-			onReload()
+			debounceMu.Lock()
+			paths := make([]string, 0, len(changed))
+			for p := range changed {
+				paths = append(paths, p)
+			}
+			clear(changed)
+			debounceMu.Unlock()
+			sort.Strings(paths)
+			onReload(paths)
 		})
 		debounceMu.Unlock()
 	}
@@ -61,22 +79,26 @@ func WatchConfigFile(ctx context.Context, configPath string, debounce time.Durat
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
 		case event, ok := <-watcher.Events:
 			if !ok {
-				return
+				return ErrWatcherClosed
 			}
 			if filepath.Clean(event.Name) != filepath.Clean(configPath) {
 				continue
 			}
 			if event.Op&(fsnotify.Create|fsnotify.Write) != 0 {
-				scheduleReload()
+				scheduleReload(event.Name)
 			}
 		case err, ok := <-watcher.Errors:
 			if !ok {
-				return
+				return ErrWatcherClosed
+			}
+			logger.Error("Config file watcher error", "path", configPath, logging.Err(err))
+			metrics.WatcherErrors.Inc("file")
+			if errors.Is(err, fsnotify.ErrEventOverflow) && onOverflow != nil {
+				onOverflow()
 			}
-			log.Printf("ERROR: Config file watcher error: %v", err)
 		}
 	}
 }
@@ -14,35 +14,34 @@ package watch
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
 	"github.com/fsnotify/fsnotify"
 )
 
 // WatchDir watches basePath (and immediate subdirs) for changes. On any create/write/remove
-// (after debounce), it calls onReload. Runs until ctx is cancelled.
-// If basePath does not exist, the watcher returns without error (caller may start it when dir appears).
-func WatchDir(ctx context.Context, basePath string, debounce time.Duration, onReload func()) {
-	if debounce <= 0 {
-		debounce = defaultDebounce
-	}
+// (after debounce), it calls onReload with the paths changed since the last call, sorted; with debounce <= 0 it calls
+// onReload on every event. If the event queue overflowed (events were lost), it calls onOverflow, if set.
+// Runs until ctx is cancelled (returning nil) or the watcher fails (returning the error; see Supervise), e.g. because
+// basePath does not exist.
+func WatchDir(ctx context.Context, basePath string, debounce time.Duration, onReload func(paths []string), onOverflow func()) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Printf("ERROR: Failed to create fsnotify watcher for dir %s: %v", basePath, err)
-		return
+		return fmt.Errorf("create fsnotify watcher: %w", err)
 	}
 	defer watcher.Close()
 
 	if err := watcher.Add(basePath); err != nil {
-		if !os.IsNotExist(err) {
-			log.Printf("ERROR: Failed to add watch on %s: %v", basePath, err)
-		}
-		return
+		return fmt.Errorf("add watch %s: %w", basePath, err)
 	}
 
 	subdirs := make(map[string]struct{})
@@ -77,13 +76,27 @@ func WatchDir(ctx context.Context, basePath string, debounce time.Duration, onRe
 
 	var debounceTimer *time.Timer
 	var debounceMu sync.Mutex
-	scheduleReload := func() {
+	changed := make(map[string]struct{})
+	scheduleReload := func(path string) {
+		if debounce <= 0 {
+			onReload([]string{path})
+			return
+		}
 		debounceMu.Lock()
+		changed[path] = struct{}{}
 		if debounceTimer != nil {
 			debounceTimer.Stop()
 		}
 		debounceTimer = time.AfterFunc(debounce, func() {
-			onReload()
+			debounceMu.Lock()
+			paths := make([]string, 0, len(changed))
+			for p := range changed {
+				paths = append(paths, p)
+			}
+			clear(changed)
+			debounceMu.Unlock()
+			sort.Strings(paths)
+			onReload(paths)
 		})
 		debounceMu.Unlock()
 	}
@@ -91,22 +104,26 @@ func WatchDir(ctx context.Context, basePath string, debounce time.Duration, onRe
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
 		case event, ok := <-watcher.Events:
 			if !ok {
-				return
+				return ErrWatcherClosed
 			}
 			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove) != 0 {
 				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && event.Op == fsnotify.Create {
 					addSubdir(event.Name)
 				}
-				scheduleReload()
+				scheduleReload(event.Name)
 			}
 		case err, ok := <-watcher.Errors:
 			if !ok {
-				return
+				return ErrWatcherClosed
+			}
+			logger.Error("Dir watcher error", "path", basePath, logging.Err(err))
+			metrics.WatcherErrors.Inc("dir")
+			if errors.Is(err, fsnotify.ErrEventOverflow) && onOverflow != nil {
+				onOverflow()
 			}
-			log.Printf("ERROR: Dir watcher error: %v", err)
 		}
 	}
 }
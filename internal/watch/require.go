@@ -0,0 +1,236 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package watch
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/fsnotify/fsnotify"
+)
+
+// waitProgressInterval is how often WaitFor logs the inputs it is still waiting for and checks them again, in case
+// an event was missed.
+const waitProgressInterval = 5 * time.Second
+
+// Requirement kinds.
+const (
+	// RequireFile is a regular file (or symlink to one).
+	RequireFile = "file"
+	// RequireDir is a directory.
+	RequireDir = "dir"
+	// RequireJWT is a directory with at least one *.jwt file.
+	RequireJWT = "jwt"
+	// RequireTLS is a certificate and private key that load as a key pair.
+	RequireTLS = "tls"
+)
+
+// Requirement is an input nats-server needs before it can start.
+type Requirement struct {
+	Kind string
+	Path string
+	// Key is the private key of a RequireTLS requirement; Path is the certificate.
+	Key string
+}
+
+func (r Requirement) String() string {
+	if r.Kind == RequireTLS {
+		return r.Kind + ":" + r.Path + ":" + r.Key
+	}
+	return r.Kind + ":" + r.Path
+}
+
+// ParseRequirements parses a comma-separated list of kind:path entries: file:<path>, dir:<path>, jwt:<dir>, and
+// tls:<cert>:<key> or tls:<dir> (for <dir>/tls.crt and <dir>/tls.key).
+func ParseRequirements(spec string) ([]Requirement, error) {
+	var out []Requirement
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kind, path, ok := strings.Cut(entry, ":")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("required input %q: want kind:path", entry)
+		}
+		r := Requirement{Kind: kind, Path: path}
+		switch kind {
+		case RequireFile, RequireDir, RequireJWT:
+		case RequireTLS:
+			if cert, key, ok := strings.Cut(path, ":"); ok {
+				if cert == "" || key == "" {
+					return nil, fmt.Errorf("required input %q: want tls:<cert>:<key> or tls:<dir>", entry)
+				}
+				r.Path, r.Key = cert, key
+			} else {
+				r.Path, r.Key = filepath.Join(path, "tls.crt"), filepath.Join(path, "tls.key")
+			}
+		default:
+			return nil, fmt.Errorf("required input %q: unknown kind %q (want file, dir, jwt or tls)", entry, kind)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// Check returns nil if the requirement is met, or an error saying what is missing.
+func (r Requirement) Check() error {
+	switch r.Kind {
+	case RequireFile:
+		if !FileExists(r.Path) {
+			return errors.New("file not found")
+		}
+	case RequireDir:
+		if info, err := os.Stat(r.Path); err != nil || !info.IsDir() {
+			return errors.New("directory not found")
+		}
+	case RequireJWT:
+		matches, _ := filepath.Glob(filepath.Join(r.Path, "*.jwt"))
+		for _, m := range matches {
+			if FileExists(m) {
+				return nil
+			}
+		}
+		return errors.New("no *.jwt file")
+	case RequireTLS:
+		if _, err := tls.LoadX509KeyPair(r.Path, r.Key); err != nil {
+			return fmt.Errorf("no usable key pair: %w", err)
+		}
+	}
+	return nil
+}
+
+// paths returns the paths whose appearance or change may meet r.
+func (r Requirement) paths() []string {
+	if r.Kind == RequireTLS {
+		return []string{r.Path, r.Key}
+	}
+	return []string{r.Path}
+}
+
+// WaitFor waits until all reqs are met, watching the paths (or their nearest existing parent directories) with
+// fsnotify and logging progress. It returns an error naming the unmet requirements once timeout has passed (0 waits
+// until ctx is done).
+func WaitFor(ctx context.Context, reqs []Requirement, timeout time.Duration) error {
+	unmet := make(map[Requirement]error)
+	check := func() {
+		for _, r := range reqs {
+			err := r.Check()
+			_, waiting := unmet[r]
+			switch {
+			case err != nil:
+				unmet[r] = err
+			case waiting:
+				delete(unmet, r)
+				logger.Info("Required input ready", "input", r.String())
+			}
+		}
+	}
+	check()
+	if len(unmet) == 0 {
+		return nil
+	}
+
+	// Without fsnotify, the progress ticker still checks again.
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Warn("Failed to create fsnotify watcher for required inputs; checking periodically", logging.Err(err))
+	} else {
+		defer watcher.Close()
+		events, watchErrors = watcher.Events, watcher.Errors
+	}
+	watched := make(map[string]bool)
+	addWatches := func() {
+		if watcher == nil {
+			return
+		}
+		for r := range unmet {
+			for _, p := range r.paths() {
+				for _, dir := range []string{p, nearestDir(filepath.Dir(p))} {
+					if info, err := os.Stat(dir); err != nil || !info.IsDir() || watched[dir] {
+						continue
+					}
+					if watcher.Add(dir) == nil {
+						watched[dir] = true
+					}
+				}
+			}
+		}
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+	progress := time.NewTicker(waitProgressInterval)
+	defer progress.Stop()
+	start := time.Now()
+	logger.Info("Waiting for required inputs", "missing", describe(unmet), "timeout", timeout)
+	for len(unmet) > 0 {
+		addWatches()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("required inputs missing after %s: %s", timeout, strings.Join(describe(unmet), "; "))
+		case <-events:
+		case err := <-watchErrors:
+			logger.Warn("Required inputs watcher error", logging.Err(err))
+		case <-progress.C:
+			check()
+			if len(unmet) > 0 {
+				logger.Info("Waiting for required inputs", "missing", describe(unmet), "elapsed", time.Since(start).Round(time.Second))
+			}
+			continue
+		}
+		check()
+	}
+	logger.Info("All required inputs ready", logging.KeyDuration, time.Since(start))
+	return nil
+}
+
+// nearestDir returns dir or its nearest existing ancestor.
+func nearestDir(dir string) string {
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// describe lists the unmet requirements with their reasons, in a stable order.
+func describe(unmet map[Requirement]error) []string {
+	out := make([]string, 0, len(unmet))
+	for r, err := range unmet {
+		out = append(out, r.String()+" ("+err.Error()+")")
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,169 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package watch
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRequirements(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []Requirement
+		wantErr string
+	}{
+		{name: "empty", spec: " , "},
+		{
+			name: "kinds",
+			spec: "file:/etc/nats/nats.conf, dir:/etc/nats/ssl,jwt:/etc/nats/jwt",
+			want: []Requirement{
+				{Kind: RequireFile, Path: "/etc/nats/nats.conf"},
+				{Kind: RequireDir, Path: "/etc/nats/ssl"},
+				{Kind: RequireJWT, Path: "/etc/nats/jwt"},
+			},
+		},
+		{
+			name: "tls dir",
+			spec: "tls:/etc/nats/ssl",
+			want: []Requirement{{Kind: RequireTLS, Path: "/etc/nats/ssl/tls.crt", Key: "/etc/nats/ssl/tls.key"}},
+		},
+		{
+			name: "tls dir with trailing slash",
+			spec: "tls:/etc/nats/ssl/",
+			want: []Requirement{{Kind: RequireTLS, Path: "/etc/nats/ssl/tls.crt", Key: "/etc/nats/ssl/tls.key"}},
+		},
+		{
+			name: "tls cert and key",
+			spec: "tls:/etc/nats/ssl/server.pem:/etc/nats/keys/server-key.pem",
+			want: []Requirement{{Kind: RequireTLS, Path: "/etc/nats/ssl/server.pem", Key: "/etc/nats/keys/server-key.pem"}},
+		},
+		{name: "tls without cert", spec: "tls::/etc/nats/ssl/server-key.pem", wantErr: "want tls:<cert>:<key> or tls:<dir>"},
+		{name: "tls without key", spec: "tls:/etc/nats/ssl/server.pem:", wantErr: "want tls:<cert>:<key> or tls:<dir>"},
+		{name: "no path", spec: "file:", wantErr: "want kind:path"},
+		{name: "no kind", spec: "/etc/nats/nats.conf", wantErr: "want kind:path"},
+		{name: "unknown kind", spec: "file:/a,socket:/b", wantErr: `unknown kind "socket"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRequirements(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRequirements(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+// writeKeyPair writes a throwaway self-signed certificate and its key as PEM files.
+func writeKeyPair(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nats"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequirementCheck(t *testing.T) {
+	dir := t.TempDir()
+	mkdir := func(name string) string {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(p, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	ssl := mkdir("ssl")
+	writeKeyPair(t, filepath.Join(ssl, "tls.crt"), filepath.Join(ssl, "tls.key"))
+	certs := mkdir("certs")
+	writeKeyPair(t, filepath.Join(certs, "server.pem"), filepath.Join(certs, "server-key.pem"))
+	writeKeyPair(t, filepath.Join(certs, "other.pem"), filepath.Join(certs, "other-key.pem"))
+	jwtDir := mkdir("jwt")
+	if err := os.WriteFile(filepath.Join(jwtDir, "ACCOUNT.jwt"), []byte("eyJ0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	emptyDir := mkdir("empty")
+
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{spec: "file:" + filepath.Join(ssl, "tls.crt")},
+		{spec: "file:" + filepath.Join(dir, "missing.conf"), wantErr: "file not found"},
+		{spec: "file:" + ssl, wantErr: "file not found"},
+		{spec: "dir:" + ssl},
+		{spec: "dir:" + filepath.Join(dir, "missing"), wantErr: "directory not found"},
+		{spec: "jwt:" + jwtDir},
+		{spec: "jwt:" + emptyDir, wantErr: "no *.jwt file"},
+		{spec: "tls:" + ssl},
+		{spec: "tls:" + emptyDir, wantErr: "no usable key pair"},
+		{spec: "tls:" + filepath.Join(certs, "server.pem") + ":" + filepath.Join(certs, "server-key.pem")},
+		{spec: "tls:" + filepath.Join(certs, "server.pem") + ":" + filepath.Join(certs, "other-key.pem"), wantErr: "no usable key pair"},
+		{spec: "tls:" + filepath.Join(certs, "server.pem") + ":" + filepath.Join(certs, "missing-key.pem"), wantErr: "no usable key pair"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.TrimPrefix(tt.spec, dir), func(t *testing.T) {
+			reqs, err := ParseRequirements(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = reqs[0].Check()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Check() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Check() = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package watch
+
+import (
+	"context"
+	"time"
+
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
+)
+
+const (
+	superviseMinBackoff = time.Second
+	superviseMaxBackoff = 30 * time.Second
+	// superviseStable is how long a watcher must run before its backoff resets.
+	superviseStable = time.Minute
+)
+
+// Supervise runs run until ctx is cancelled, restarting it with backoff (1s, doubling up to 30s) whenever it returns.
+// onExit, if set, is called with the error of each exit before the restart; events may have been missed while the
+// watcher was down. name labels logs and pot_nats_watcher_restarts_total.
+func Supervise(ctx context.Context, name string, run func(ctx context.Context) error, onExit func(err error)) {
+	backoff := superviseMinBackoff
+	for {
+		started := time.Now()
+		err := run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = ErrWatcherClosed
+		}
+		if time.Since(started) >= superviseStable {
+			backoff = superviseMinBackoff
+		}
+		logger.Error("Watcher stopped, restarting", "watcher", name, "backoff", backoff, logging.Err(err))
+		metrics.WatcherRestarts.Inc(name)
+		if onExit != nil {
+			onExit(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, superviseMaxBackoff)
+	}
+}
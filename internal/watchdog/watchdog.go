@@ -0,0 +1,250 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
+	"github.com/datasance/nats-server/internal/natsclient"
+	"github.com/nats-io/nats.go"
+)
+
+var logger = logging.Component("watchdog")
+
+// maxVarzLog caps the /varz body logged as diagnostics, maxStackszLog the goroutine dump of /stacksz.
+const (
+	maxVarzLog    = 16 * 1024
+	maxStackszLog = 1024 * 1024
+)
+
+// Process is the supervised nats-server child.
+type Process interface {
+	Running() bool
+	StartTime() time.Time
+	Signal(sig os.Signal) error
+}
+
+// Options configures a Watchdog.
+type Options struct {
+	// Interval between probes.
+	Interval time.Duration
+	// Timeout of each probe.
+	Timeout time.Duration
+	// Failures is the number of consecutive failed probes after which the server is considered wedged.
+	Failures int
+	// StartGrace skips probes for this long after a (re)start.
+	StartGrace time.Duration
+	// KillTimeout is how long to wait for the process to exit after SIGQUIT before sending SIGKILL.
+	KillTimeout time.Duration
+	// Restart terminates a wedged server so the supervisor restarts it; without it, the watchdog only logs
+	// diagnostics, with the goroutine dump taken from /stacksz instead of SIGQUIT.
+	Restart bool
+	// MonitorPort of nats-server; 0 skips the /healthz probe.
+	MonitorPort int
+	// ClientURL and CredsPath are used for the client round-trip probe; empty ClientURL skips it.
+	ClientURL string
+	CredsPath string
+}
+
+// Hooks connect a Watchdog to the supervisor of nats-server.
+type Hooks struct {
+	// Skip is consulted before each probe (e.g. restart in progress).
+	Skip func() bool
+	// Prepare is called before the wedged process is signalled, so the supervisor restarts it instead of treating
+	// the exit as a crash.
+	Prepare func()
+	// Abort undoes Prepare when the process could not be signalled and is still running.
+	Abort func()
+}
+
+// Watchdog probes nats-server through /healthz and a client round-trip. After Options.Failures consecutive
+// failures while the process is still alive, it logs /varz and, with Options.Restart, sends SIGQUIT (Go runtime
+// goroutine dump on the child's stderr, then exit) and SIGKILL if the process does not exit, so the supervisor
+// restarts it. Without Options.Restart, it logs the goroutine dump of /stacksz instead.
+type Watchdog struct {
+	proc   Process
+	opts   Options
+	client *http.Client
+}
+
+// New returns a Watchdog for proc.
+func New(proc Process, opts Options) *Watchdog {
+	return &Watchdog{proc: proc, opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+}
+
+// Run probes until ctx is cancelled.
+func (w *Watchdog) Run(ctx context.Context, h Hooks) {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		started := w.proc.StartTime()
+		if !w.proc.Running() || started.IsZero() || time.Since(started) < w.opts.StartGrace || (h.Skip != nil && h.Skip()) {
+			failures = 0
+			continue
+		}
+		if err := w.probe(ctx); err != nil {
+			failures++
+			logger.Warn("nats-server probe failed", "failures", failures, "threshold", w.opts.Failures, logging.Err(err))
+			if failures < w.opts.Failures {
+				continue
+			}
+			failures = 0
+			w.recover(h)
+			continue
+		}
+		if failures > 0 {
+			logger.Info("nats-server answering again", "failures", failures)
+		}
+		failures = 0
+	}
+}
+
+// probe returns nil if either the server answers /healthz or a client round-trip, unless the enabled probes
+// all fail. A /healthz error status (e.g. JetStream not current) still proves the server is answering.
+func (w *Watchdog) probe(ctx context.Context) error {
+	var errs []error
+	probes := 0
+	if w.opts.MonitorPort > 0 {
+		probes++
+		if err := w.probeHealthz(ctx); err != nil {
+			metrics.WatchdogProbeFailures.Inc("healthz")
+			errs = append(errs, fmt.Errorf("healthz: %w", err))
+		}
+	}
+	if w.opts.ClientURL != "" {
+		probes++
+		if err := w.probeClient(); err != nil {
+			metrics.WatchdogProbeFailures.Inc("client")
+			errs = append(errs, fmt.Errorf("client: %w", err))
+		}
+	}
+	if probes > 0 && len(errs) == probes {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func (w *Watchdog) probeHealthz(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.monitorURL("/healthz?js-enabled-only=true"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (w *Watchdog) probeClient() error {
+	opts, err := natsclient.Options(w.opts.CredsPath)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, nats.Timeout(w.opts.Timeout), nats.NoReconnect())
+	nc, err := nats.Connect(w.opts.ClientURL, opts...)
+	if err != nil {
+		// The server answered the handshake; auth problems are not a hang.
+		if errors.Is(err, nats.ErrAuthorization) || errors.Is(err, nats.ErrAuthExpired) || errors.Is(err, nats.ErrAuthRevoked) {
+			return nil
+		}
+		return err
+	}
+	defer nc.Close()
+	return nc.FlushTimeout(w.opts.Timeout)
+}
+
+// recover captures diagnostics and, with Options.Restart, terminates the wedged process so the supervisor restarts it.
+func (w *Watchdog) recover(h Hooks) {
+	if w.opts.Restart {
+		logger.Error("nats-server is not answering, capturing diagnostics and restarting")
+	} else {
+		logger.Error("nats-server is not answering, capturing diagnostics (restart disabled)")
+	}
+	if w.opts.MonitorPort > 0 {
+		if body, err := w.fetch(w.monitorURL("/varz"), maxVarzLog); err != nil {
+			logger.Warn("/varz unavailable", logging.Err(err))
+		} else {
+			logger.Info("/varz of the wedged server", "varz", body)
+		}
+	}
+	if !w.opts.Restart {
+		// The server keeps running, so the goroutine dump comes from the monitoring port instead of SIGQUIT.
+		if w.opts.MonitorPort == 0 {
+			logger.Warn("No goroutine dump: monitoring port disabled")
+		} else if body, err := w.fetch(w.monitorURL("/stacksz"), maxStackszLog); err != nil {
+			logger.Warn("/stacksz unavailable", logging.Err(err))
+		} else {
+			logger.Info("/stacksz of the wedged server", "stacksz", body)
+		}
+		return
+	}
+	if h.Prepare != nil {
+		h.Prepare()
+	}
+	// SIGQUIT is not handled by nats-server: the Go runtime dumps all goroutines to stderr and exits.
+	if err := w.proc.Signal(syscall.SIGQUIT); err != nil {
+		logger.Error("SIGQUIT failed", logging.Err(err))
+	} else {
+		deadline := time.Now().Add(w.opts.KillTimeout)
+		for w.proc.Running() && time.Now().Before(deadline) {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if !w.proc.Running() {
+			metrics.WatchdogRestarts.Inc()
+			return
+		}
+		logger.Warn("nats-server did not exit after SIGQUIT, sending SIGKILL", "timeout", w.opts.KillTimeout)
+	}
+	if err := w.proc.Signal(syscall.SIGKILL); err != nil && w.proc.Running() {
+		// Still running: the supervisor must not mistake a later exit for this restart.
+		logger.Error("SIGKILL failed, nats-server not restarted", logging.Err(err))
+		if h.Abort != nil {
+			h.Abort()
+		}
+		return
+	}
+	metrics.WatchdogRestarts.Inc()
+}
+
+// fetch returns the first limit bytes of the body at url.
+func (w *Watchdog) fetch(url string, limit int64) (string, error) {
+	resp, err := w.client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	return string(body), err
+}
+
+func (w *Watchdog) monitorURL(path string) string {
+	return "http://127.0.0.1:" + strconv.Itoa(w.opts.MonitorPort) + path
+}
@@ -0,0 +1,244 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datasance/nats-server/internal/events"
+	"github.com/datasance/nats-server/internal/logging"
+	"github.com/datasance/nats-server/internal/metrics"
+	"github.com/nats-io/nuid"
+)
+
+var logger = logging.Component("webhook")
+
+// sinkWebhook is the sink name of webhooks in metrics.
+const sinkWebhook = "webhook"
+
+// Webhook kinds: the event selections a webhook can be configured for.
+const (
+	KindReloadFailed   = "reload_failed"
+	KindLeafRestarted  = "leaf_restarted"
+	KindCrashLoop      = "crash_loop"
+	KindPurgeInitiated = "purge_initiated"
+	KindJWTRejected    = "jwt_rejected"
+	// KindTest is sent by Sender.Test.
+	KindTest = "test"
+)
+
+// Request headers.
+const (
+	HeaderKind      = "X-Pot-Event"
+	HeaderDelivery  = "X-Pot-Delivery"
+	HeaderTimestamp = "X-Pot-Timestamp"
+	HeaderSignature = "X-Pot-Signature"
+)
+
+const (
+	initialBackoff = time.Second
+	maxBackoff     = 30 * time.Second
+)
+
+// Kind returns the webhook kind of e, or "" if e is not one of the kinds.
+func Kind(e events.Event) string {
+	switch e.Type {
+	case events.TypeReload:
+		if o, _ := e.Data["outcome"].(string); o == metrics.OutcomeFailure || o == metrics.OutcomeRejected {
+			return KindReloadFailed
+		}
+	case events.TypeRestart:
+		if r, _ := e.Data["reason"].(string); r == events.ReasonLeafConfigChange {
+			return KindLeafRestarted
+		}
+	case events.TypeCrashLoop:
+		return KindCrashLoop
+	case events.TypePurge:
+		if o, _ := e.Data["outcome"].(string); o == events.PurgeInitiated {
+			return KindPurgeInitiated
+		}
+	case events.TypeClaimsRejected:
+		return KindJWTRejected
+	}
+	return ""
+}
+
+// Payload is the JSON body of a webhook request.
+type Payload struct {
+	Kind  string       `json:"kind"`
+	Host  string       `json:"host,omitempty"`
+	Event events.Event `json:"event"`
+}
+
+// Options configures webhook delivery.
+type Options struct {
+	// URLs receive every selected event.
+	URLs []string
+	// Secret signs each request with HMAC-SHA256; empty disables signing.
+	Secret string
+	// Kinds selects the events sent; see the Kind* constants.
+	Kinds []string
+	// Timeout of each request.
+	Timeout time.Duration
+	// MaxRetries after the first attempt, with exponential backoff from 1s up to 30s.
+	MaxRetries int
+	// QueueSize bounds the events waiting for delivery; further events are dropped.
+	QueueSize int
+}
+
+// Sender delivers payloads to the configured URLs.
+type Sender struct {
+	opts   Options
+	client *http.Client
+	host   string
+	kinds  map[string]bool
+	// backoff is the wait before the first retry; it doubles up to maxBackoff.
+	backoff time.Duration
+}
+
+// Kinds lists the kinds a webhook can be configured for.
+var Kinds = []string{KindReloadFailed, KindLeafRestarted, KindCrashLoop, KindPurgeInitiated, KindJWTRejected}
+
+// New returns a Sender for opts. It fails on an unknown kind.
+func New(opts Options) (*Sender, error) {
+	host, _ := os.Hostname()
+	kinds := make(map[string]bool, len(opts.Kinds))
+	for _, k := range opts.Kinds {
+		if !slices.Contains(Kinds, k) {
+			return nil, fmt.Errorf("unknown webhook event %q (valid: %s)", k, strings.Join(Kinds, ", "))
+		}
+		kinds[k] = true
+	}
+	return &Sender{opts: opts, client: &http.Client{Timeout: opts.Timeout}, host: host, kinds: kinds, backoff: initialBackoff}, nil
+}
+
+// Subscribe delivers the selected events of b from a dedicated goroutine with its own bounded queue, so a slow
+// or unreachable receiver never blocks the reload pipeline.
+func (s *Sender) Subscribe(b *events.Bus) {
+	b.Subscribe(sinkWebhook, s.opts.QueueSize, func(e events.Event) {
+		kind := Kind(e)
+		if kind == "" || !s.kinds[kind] {
+			return
+		}
+		s.deliver(context.Background(), Payload{Kind: kind, Host: s.host, Event: e})
+	})
+	logger.Info("Webhook notifications enabled", "urls", len(s.opts.URLs), "kinds", s.opts.Kinds)
+}
+
+// Test sends a test payload to every URL once, without retries, and returns the first error.
+func (s *Sender) Test(ctx context.Context) error {
+	p := Payload{Kind: KindTest, Host: s.host, Event: events.Event{Type: KindTest, Time: time.Now().UTC()}}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	for _, url := range s.opts.URLs {
+		if _, err := s.post(ctx, url, p.Kind, nuid.Next(), body); err != nil {
+			return fmt.Errorf("%s: %w", url, err)
+		}
+	}
+	return nil
+}
+
+// deliver posts p to every URL, retrying each with backoff.
+func (s *Sender) deliver(ctx context.Context, p Payload) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		metrics.Events.Inc(sinkWebhook, metrics.OutcomeFailure)
+		return
+	}
+	delivery := nuid.Next()
+	for _, url := range s.opts.URLs {
+		if err := s.postWithRetry(ctx, url, p.Kind, delivery, body); err != nil {
+			logger.Error("Webhook delivery failed", "url", url, "kind", p.Kind, logging.Err(err))
+			metrics.Events.Inc(sinkWebhook, metrics.OutcomeFailure)
+			continue
+		}
+		metrics.Events.Inc(sinkWebhook, metrics.OutcomeSuccess)
+	}
+}
+
+func (s *Sender) postWithRetry(ctx context.Context, url, kind, delivery string, body []byte) error {
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, url, kind, delivery, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.opts.MaxRetries {
+			return err
+		}
+		logger.Warn("Webhook delivery failed, retrying", "url", url, "kind", kind, "attempt", attempt+1, "backoff", backoff, logging.Err(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// post sends one request; delivery identifies the event across retries. retry reports whether the failure is worth
+// retrying (network error, 429, 5xx).
+func (s *Sender) post(ctx context.Context, url, kind, delivery string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pot-nats")
+	req.Header.Set(HeaderKind, kind)
+	req.Header.Set(HeaderDelivery, delivery)
+	req.Header.Set(HeaderTimestamp, ts)
+	if s.opts.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(s.opts.Secret, ts, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode/100 == 2:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return false, fmt.Errorf("receiver returned %s", resp.Status)
+}
+
+// Sign returns the X-Pot-Signature value for body sent at timestamp ts (unix seconds, X-Pot-Timestamp):
+// "sha256=" + hex(HMAC-SHA256(secret, ts + "." + body)). Receivers recompute it and compare in constant time,
+// and may reject old timestamps to prevent replays.
+func Sign(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,181 @@
+/*
+ *  *******************************************************************************
+ *  * Copyright (c) 2023 Datasance Teknoloji A.S.
+ *  *
+ *  * This program and the accompanying materials are made available under the
+ *  * terms of the Eclipse Public License v. 2.0 which is available at
+ *  * http://www.eclipse.org/legal/epl-2.0
+ *  *
+ *  * SPDX-License-Identifier: EPL-2.0
+ *  *******************************************************************************
+ */
+
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/datasance/nats-server/internal/events"
+)
+
+// receiver is an httptest server answering with the next status of statuses (200 once they run out) and recording
+// each request.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	// block, if set, holds each request until it is closed; entered gets a value when a request arrives.
+	block   chan struct{}
+	entered chan struct{}
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rc := &receiver{statuses: statuses}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		rc.requests = append(rc.requests, r)
+		rc.bodies = append(rc.bodies, body)
+		status := http.StatusOK
+		if len(rc.statuses) > 0 {
+			status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+		}
+		block, entered := rc.block, rc.entered
+		rc.mu.Unlock()
+		if entered != nil {
+			entered <- struct{}{}
+		}
+		if block != nil {
+			<-block
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+func newTestSender(t *testing.T, opts Options) *Sender {
+	t.Helper()
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	s, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.backoff = time.Millisecond
+	return s
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256("whsec-test", `1700000000.{"kind":"test"}`), computed with openssl dgst -sha256 -hmac.
+	const want = "sha256=454143f1532cdf4875295398c04b1ff35f08766b374f6de114b2f6d26914931d"
+	if got := Sign("whsec-test", "1700000000", []byte(`{"kind":"test"}`)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestDeliverHeaders(t *testing.T) {
+	rc := newReceiver(t)
+	s := newTestSender(t, Options{URLs: []string{rc.URL}, Secret: "whsec-test"})
+	e := events.Event{Type: events.TypeCrashLoop, Time: time.Now().UTC(), Data: map[string]any{"crashes": 3}}
+	s.deliver(t.Context(), Payload{Kind: KindCrashLoop, Host: "edge1", Event: e})
+
+	if rc.count() != 1 {
+		t.Fatalf("%d requests, want 1", rc.count())
+	}
+	req, body := rc.requests[0], rc.bodies[0]
+	for header, want := range map[string]string{
+		"Content-Type":  "application/json",
+		HeaderKind:      KindCrashLoop,
+		HeaderSignature: Sign("whsec-test", req.Header.Get(HeaderTimestamp), body),
+	} {
+		if got := req.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if req.Header.Get(HeaderDelivery) == "" {
+		t.Errorf("%s missing", HeaderDelivery)
+	}
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Kind != KindCrashLoop || p.Host != "edge1" || p.Event.Type != events.TypeCrashLoop {
+		t.Errorf("payload = %+v", p)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		maxRetries int
+		want       int
+	}{
+		{name: "success", want: 1},
+		{name: "retry on 5xx", statuses: []int{500, 503}, maxRetries: 3, want: 3},
+		{name: "retry on 429", statuses: []int{429}, maxRetries: 3, want: 2},
+		{name: "retries exhausted", statuses: []int{502, 502, 502, 502}, maxRetries: 2, want: 3},
+		{name: "no retry on 4xx", statuses: []int{400}, maxRetries: 3, want: 1},
+		{name: "no retry on 404", statuses: []int{404}, maxRetries: 3, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newReceiver(t, tt.statuses...)
+			s := newTestSender(t, Options{URLs: []string{rc.URL}, MaxRetries: tt.maxRetries})
+			s.deliver(t.Context(), Payload{Kind: KindCrashLoop, Event: events.Event{Type: events.TypeCrashLoop}})
+			if got := rc.count(); got != tt.want {
+				t.Errorf("%d requests, want %d", got, tt.want)
+			}
+			// Retries are the same delivery.
+			for _, req := range rc.requests[1:] {
+				if got, want := req.Header.Get(HeaderDelivery), rc.requests[0].Header.Get(HeaderDelivery); got != want {
+					t.Errorf("retry delivery = %s, want %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSubscribeDropsWhenQueueFull(t *testing.T) {
+	rc := newReceiver(t)
+	rc.block = make(chan struct{})
+	rc.entered = make(chan struct{}, 16)
+	s := newTestSender(t, Options{URLs: []string{rc.URL}, Kinds: []string{KindCrashLoop}, QueueSize: 2})
+	bus := new(events.Bus)
+	s.Subscribe(bus)
+
+	emit := func() { bus.Emit(events.Event{Type: events.TypeCrashLoop, Time: time.Now().UTC()}) }
+	// The first event is taken off the queue and held at the receiver; two more fill the queue, the rest are dropped.
+	emit()
+	select {
+	case <-rc.entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first event not delivered")
+	}
+	for range 5 {
+		emit()
+	}
+	close(rc.block)
+	bus.Flush(5 * time.Second)
+
+	if got := rc.count(); got != 3 {
+		t.Errorf("%d requests, want 3 (1 in flight + queue of 2)", got)
+	}
+}